@@ -0,0 +1,121 @@
+package cache
+
+import (
+	"appoller/client"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// fileName is the cache file inside the data directory.
+const fileName = "monitors.json"
+
+// Snapshot is the last registration and monitor list received from the API.
+type Snapshot struct {
+	SavedAt      time.Time                  `json:"saved_at"`
+	Registration *client.RegisterResponse   `json:"registration,omitempty"`
+	Monitors     []client.MonitorAssignment `json:"monitors"`
+}
+
+// Store persists the latest Snapshot so the poller can keep checking when it
+// starts without a reachable API.
+type Store struct {
+	mu   sync.Mutex
+	path string
+	snap Snapshot
+	last []byte // last written contents, minus SavedAt, to skip no-op writes
+}
+
+// Open loads the cache in dir. A missing file yields an empty store.
+func Open(dir string) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create data dir: %w", err)
+	}
+	s := &Store{path: filepath.Join(dir, fileName)}
+
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read monitor cache: %w", err)
+	}
+	if err := json.Unmarshal(data, &s.snap); err != nil {
+		return nil, fmt.Errorf("failed to decode monitor cache: %w", err)
+	}
+	return s, nil
+}
+
+// Snapshot returns the cached state.
+func (s *Store) Snapshot() Snapshot {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.snap
+}
+
+// SetRegistration records a successful registration.
+func (s *Store) SetRegistration(reg *client.RegisterResponse) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snap.Registration = reg
+	return s.save()
+}
+
+// SetMonitors records the latest monitor list.
+func (s *Store) SetMonitors(monitors []client.MonitorAssignment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snap.Monitors = monitors
+	return s.save()
+}
+
+// save writes the snapshot atomically. Callers hold s.mu.
+func (s *Store) save() error {
+	snap := s.snap
+	snap.SavedAt = time.Time{}
+	body, err := json.Marshal(snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode monitor cache: %w", err)
+	}
+	if bytes.Equal(body, s.last) {
+		return nil
+	}
+
+	s.snap.SavedAt = time.Now().UTC()
+	data, err := json.Marshal(s.snap)
+	if err != nil {
+		return fmt.Errorf("failed to encode monitor cache: %w", err)
+	}
+	if err := WriteFileAtomic(s.path, data); err != nil {
+		return fmt.Errorf("failed to write monitor cache: %w", err)
+	}
+	s.last = body
+	return nil
+}
+
+// WriteFileAtomic replaces path with data via a synced temp file and rename,
+// so a crash never leaves a truncated file behind.
+func WriteFileAtomic(path string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,152 @@
+package checker
+
+import (
+	"appoller/client"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// evaluateJSONAssertions checks each assertion against the JSON response body
+// and returns a description of every assertion that failed.
+func evaluateJSONAssertions(body []byte, assertions []client.JSONAssertion) []string {
+	doc, err := decodeJSONBody(body)
+	if err != nil {
+		return []string{fmt.Sprintf("response is not valid JSON: %v", err)}
+	}
+
+	var failures []string
+	for _, a := range assertions {
+		if msg := evaluateJSONAssertion(doc, a); msg != "" {
+			failures = append(failures, msg)
+		}
+	}
+	return failures
+}
+
+// decodeJSONBody decodes a response body, keeping numbers as json.Number so
+// they compare exactly as written.
+func decodeJSONBody(body []byte) (interface{}, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var doc interface{}
+	if err := dec.Decode(&doc); err != nil {
+		return nil, err
+	}
+	return doc, nil
+}
+
+// evaluateJSONAssertion returns an empty string when the assertion holds.
+func evaluateJSONAssertion(doc interface{}, a client.JSONAssertion) string {
+	value, found, err := lookupJSONPath(doc, a.Path)
+	if err != nil {
+		return err.Error()
+	}
+
+	// "not-equals" and "not_equals" are the same operator
+	op := strings.ReplaceAll(strings.ToLower(a.Operator), "-", "_")
+	switch op {
+	case "exists":
+		if !found {
+			return fmt.Sprintf("%s does not exist", a.Path)
+		}
+		return ""
+	case "not_exists":
+		if found {
+			return fmt.Sprintf("%s exists (got %s)", a.Path, jsonValueString(value))
+		}
+		return ""
+	}
+
+	if !found {
+		return fmt.Sprintf("%s %s %q: path not found", a.Path, op, a.Value)
+	}
+	actual := jsonValueString(value)
+
+	var ok bool
+	switch op {
+	case "equals", "eq", "":
+		ok = jsonEqual(value, actual, a.Value)
+	case "not_equals", "ne":
+		ok = !jsonEqual(value, actual, a.Value)
+	case "gt", "gte", "lt", "lte":
+		got, err1 := strconv.ParseFloat(actual, 64)
+		want, err2 := strconv.ParseFloat(a.Value, 64)
+		if err1 != nil || err2 != nil {
+			return fmt.Sprintf("%s %s %q: cannot compare %s numerically", a.Path, op, a.Value, actual)
+		}
+		switch op {
+		case "gt":
+			ok = got > want
+		case "gte":
+			ok = got >= want
+		case "lt":
+			ok = got < want
+		case "lte":
+			ok = got <= want
+		}
+	case "contains":
+		if arr, isArr := value.([]interface{}); isArr {
+			for _, elem := range arr {
+				if jsonValueString(elem) == a.Value {
+					ok = true
+					break
+				}
+			}
+		} else {
+			ok = strings.Contains(actual, a.Value)
+		}
+	case "matches":
+		re, err := regexp.Compile(a.Value)
+		if err != nil {
+			return fmt.Sprintf("%s matches %q: invalid regular expression: %v", a.Path, a.Value, err)
+		}
+		ok = re.MatchString(actual)
+	default:
+		return fmt.Sprintf("%s: unknown assertion operator %q", a.Path, a.Operator)
+	}
+
+	if !ok {
+		return fmt.Sprintf("%s %s %q (got %s)", a.Path, op, a.Value, actual)
+	}
+	return ""
+}
+
+// jsonEqual compares a decoded value with the expected string. Numbers are
+// compared by value when the expected string is a number too, so 1, 1.0 and
+// 1e0 are equal; large integers are compared exactly.
+func jsonEqual(value interface{}, actual, expected string) bool {
+	if actual == expected {
+		return true
+	}
+	if _, isNum := value.(json.Number); !isNum {
+		return false
+	}
+	got, ok1 := new(big.Rat).SetString(actual)
+	want, ok2 := new(big.Rat).SetString(expected)
+	return ok1 && ok2 && got.Cmp(want) == 0
+}
+
+// jsonValueString renders a decoded JSON value for comparison. Strings are
+// returned unquoted; everything else uses its JSON encoding.
+func jsonValueString(v interface{}) string {
+	switch t := v.(type) {
+	case string:
+		return t
+	case json.Number:
+		return t.String()
+	case nil:
+		return "null"
+	default:
+		data, err := json.Marshal(t)
+		if err != nil {
+			return fmt.Sprintf("%v", t)
+		}
+		return string(data)
+	}
+}
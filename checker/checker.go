@@ -2,10 +2,21 @@ package checker
 
 import (
 	"appoller/client"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"time"
 )
 
-const maxResponseBodySize = 10 * 1024 // 10KB
+const (
+	maxResponseBodySize  = 10 * 1024   // 10KB
+	maxAssertionBodySize = 1024 * 1024 // 1MB
+
+	// maxConfirmAttempts caps failure confirmation regardless of the
+	// monitor's FailureThreshold.
+	maxConfirmAttempts = 5
+)
 
 // Result is the outcome of a single check execution.
 type Result struct {
@@ -17,32 +28,83 @@ type Result struct {
 	StatusCode     int
 	ResponseTimeMs int64
 	ErrorMessage   string
+	ErrorCategory  string // one of the Category* constants when Success is false
 	ResponseBody   string
+	Timings        *client.PhaseTimings // HTTP checks only
+	Steps          []client.StepResult  // api_flow checks only
+	FailedStep     int                  // 1-based index of the failing api_flow step
+	Attempts       int                  // executions including failure confirmations
+	Skipped        bool                 // not executed; ErrorCategory says why
+	ConfigRevision string               // revision of the monitor definition used
+	Maintenance    string               // maintenance window ID, if the check fell in one
+	SuppressedBy   string               // parent monitor UUID, when reported as parent_down
 }
 
-// Execute runs the appropriate check based on monitor type.
-func Execute(m *client.MonitorAssignment, tlsInsecure bool) *Result {
-	result := &Result{
-		MonitorUUID: m.UUID,
-		Subdomain:   m.Subdomain,
-		Location:    m.Location,
-		CheckedAt:   time.Now().UTC(),
+// NewResult returns a Result pre-filled with the monitor's identity and the
+// current time. Checkers should start from it.
+func NewResult(m *client.MonitorAssignment) *Result {
+	return &Result{
+		MonitorUUID:    m.UUID,
+		Subdomain:      m.Subdomain,
+		Location:       m.Location,
+		CheckedAt:      time.Now().UTC(),
+		ConfigRevision: m.ConfigRevision,
 	}
+}
+
+// Skipped returns a Result recording that the monitor's check was not run.
+func Skipped(m *client.MonitorAssignment, category, message string) *Result {
+	r := NewResult(m)
+	r.Skipped = true
+	r.ErrorCategory = category
+	r.ErrorMessage = message
+	return r
+}
+
+// ParentDown returns a Result recording that the monitor was not checked
+// because the parent monitor it depends on is down.
+func ParentDown(m *client.MonitorAssignment, parentUUID string) *Result {
+	r := Skipped(m, CategoryParentDown, "unreachable: parent down ("+parentUUID+")")
+	r.SuppressedBy = parentUUID
+	return r
+}
 
-	switch m.MonitorType {
-	case "http", "api":
-		return performHTTPCheck(m, tlsInsecure)
-	case "dns":
-		return performDNSCheck(m)
-	case "tcp":
-		return performTCPCheck(m)
-	case "ssl":
-		return performSSLCheck(m)
-	default:
+// Execute runs the checker registered for the monitor's type. Cancelling ctx
+// aborts the check.
+//
+// A failing check is re-run locally, up to the monitor's FailureThreshold
+// attempts in total, and is reported as failed only if every attempt fails.
+func Execute(ctx context.Context, m *client.MonitorAssignment, opts Options) *Result {
+	c, ok := Lookup(m.MonitorType)
+	if !ok {
+		result := NewResult(m)
 		result.Success = false
 		result.ErrorMessage = "unknown monitor type: " + m.MonitorType
+		result.ErrorCategory = CategoryInvalidConfig
 		return result
 	}
+
+	attempts := m.FailureThreshold
+	if attempts < 1 {
+		attempts = 1
+	}
+	if attempts > maxConfirmAttempts {
+		attempts = maxConfirmAttempts
+	}
+
+	for attempt := 1; ; attempt++ {
+		result := c.Check(ctx, m, opts)
+		result.Attempts = attempt
+		if result.Success || attempt >= attempts {
+			return result
+		}
+
+		select {
+		case <-ctx.Done():
+			return result
+		case <-time.After(opts.ConfirmDelay):
+		}
+	}
 }
 
 // ToClientResult converts a Result to a client.CheckResult for API submission.
@@ -57,6 +119,23 @@ func (r *Result) ToClientResult(pollerUUID string) client.CheckResult {
 		StatusCode:     r.StatusCode,
 		ResponseTimeMs: r.ResponseTimeMs,
 		ErrorMessage:   r.ErrorMessage,
+		ErrorCategory:  r.ErrorCategory,
 		ResponseBody:   r.ResponseBody,
+		Timings:        r.Timings,
+		Steps:          r.Steps,
+		FailedStep:     r.FailedStep,
+		Attempts:       r.Attempts,
+		Skipped:        r.Skipped,
+		ConfigRevision: r.ConfigRevision,
+		Maintenance:    r.Maintenance,
+		SuppressedBy:   r.SuppressedBy,
 	}
 }
+
+// Hash summarises the outcome of a check — success, status code, error
+// category and failing step — so consecutive results can be compared
+// without storing them. Timings and error messages are left out.
+func (r *Result) Hash() string {
+	sum := sha256.Sum256(fmt.Appendf(nil, "%t|%d|%s|%d", r.Success, r.StatusCode, r.ErrorCategory, r.FailedStep))
+	return hex.EncodeToString(sum[:8])
+}
@@ -0,0 +1,132 @@
+package checker
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"syscall"
+)
+
+// Error categories reported with failed checks so the API can group and route
+// failures without parsing ErrorMessage.
+const (
+	CategoryDNSNXDomain        = "dns_nxdomain"
+	CategoryDNSTimeout         = "dns_timeout"
+	CategoryDNSFailure         = "dns_failure"
+	CategoryDNSNoRecords       = "dns_no_records"
+	CategoryDNSMismatch        = "dns_mismatch"
+	CategoryConnectionRefused  = "connection_refused"
+	CategoryConnectionReset    = "connection_reset"
+	CategoryConnectTimeout     = "connect_timeout"
+	CategoryHostUnreachable    = "host_unreachable"
+	CategoryNetworkError       = "network_error"
+	CategoryTLSHandshake       = "tls_handshake"
+	CategoryCertInvalid        = "cert_invalid"
+	CategoryCertExpiring       = "cert_expiring"
+	CategoryReadTimeout        = "read_timeout"
+	CategoryTimeout            = "timeout"
+	CategoryTooManyRedirects   = "too_many_redirects"
+	CategoryHTTPStatusMismatch = "http_status_mismatch"
+	CategoryBodyAssertion      = "body_assertion"
+	CategoryExtractionFailed   = "extraction_failed"
+	CategoryInvalidConfig      = "invalid_config"
+	CategoryCanceled           = "canceled"
+	CategoryPollerOverloaded   = "poller_overloaded" // check skipped, not executed
+	CategoryMaintenance        = "maintenance"       // check skipped during a maintenance window
+	CategoryParentDown         = "parent_down"       // a monitor this one depends on is down
+	CategoryUnknown            = "unknown"
+)
+
+// errTooManyRedirects is returned by the HTTP redirect policy.
+var errTooManyRedirects = errors.New("too many redirects")
+
+// classifyError maps an error from a network operation to a category.
+func classifyError(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	if errors.Is(err, context.Canceled) {
+		return CategoryCanceled
+	}
+	if errors.Is(err, errTooManyRedirects) {
+		return CategoryTooManyRedirects
+	}
+
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) {
+		switch {
+		case dnsErr.IsNotFound:
+			return CategoryDNSNXDomain
+		case dnsErr.IsTimeout:
+			return CategoryDNSTimeout
+		default:
+			return CategoryDNSFailure
+		}
+	}
+
+	if isCertError(err) {
+		return CategoryCertInvalid
+	}
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	if errors.As(err, &recordErr) || errors.As(err, &alertErr) {
+		return CategoryTLSHandshake
+	}
+
+	switch {
+	case errors.Is(err, syscall.ECONNREFUSED):
+		return CategoryConnectionRefused
+	case errors.Is(err, syscall.ECONNRESET), errors.Is(err, syscall.EPIPE),
+		errors.Is(err, io.EOF), errors.Is(err, io.ErrUnexpectedEOF):
+		return CategoryConnectionReset
+	case errors.Is(err, syscall.EHOSTUNREACH), errors.Is(err, syscall.ENETUNREACH):
+		return CategoryHostUnreachable
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		if opErr.Timeout() {
+			if opErr.Op == "dial" {
+				return CategoryConnectTimeout
+			}
+			return CategoryReadTimeout
+		}
+		if opErr.Op == "dial" {
+			return CategoryNetworkError
+		}
+	}
+
+	// net/http reports handshake failures and timeouts with unexported types.
+	msg := err.Error()
+	if strings.Contains(msg, "TLS handshake") || strings.Contains(msg, "tls: ") ||
+		strings.Contains(msg, "HTTP response to HTTPS client") {
+		return CategoryTLSHandshake
+	}
+
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return CategoryTimeout
+	}
+
+	if errors.As(err, &opErr) {
+		return CategoryNetworkError
+	}
+	return CategoryUnknown
+}
+
+// isCertError reports whether err is a certificate verification failure.
+func isCertError(err error) bool {
+	var verifyErr *tls.CertificateVerificationError
+	var invalidErr x509.CertificateInvalidError
+	var authorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	return errors.As(err, &verifyErr) ||
+		errors.As(err, &invalidErr) ||
+		errors.As(err, &authorityErr) ||
+		errors.As(err, &hostnameErr)
+}
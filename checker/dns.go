@@ -9,13 +9,12 @@ import (
 	"time"
 )
 
-func performDNSCheck(m *client.MonitorAssignment) *Result {
-	result := &Result{
-		MonitorUUID: m.UUID,
-		Subdomain:   m.Subdomain,
-		Location:    m.Location,
-		CheckedAt:   time.Now().UTC(),
-	}
+func init() {
+	Register("dns", CheckerFunc(performDNSCheck))
+}
+
+func performDNSCheck(ctx context.Context, m *client.MonitorAssignment, opts Options) *Result {
+	result := NewResult(m)
 
 	hostname := m.URL
 	hostname = strings.TrimPrefix(hostname, "http://")
@@ -40,7 +39,7 @@ func performDNSCheck(m *client.MonitorAssignment) *Result {
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	ctx, cancel := context.WithTimeout(ctx, timeout)
 	defer cancel()
 
 	start := time.Now()
@@ -92,12 +91,17 @@ func performDNSCheck(m *client.MonitorAssignment) *Result {
 	if lookupErr != nil {
 		result.Success = false
 		result.ErrorMessage = fmt.Sprintf("DNS lookup failed: %v", lookupErr)
+		result.ErrorCategory = classifyError(lookupErr)
+		if result.ErrorCategory == CategoryTimeout {
+			result.ErrorCategory = CategoryDNSTimeout
+		}
 		return result
 	}
 
 	if len(resolvedAddresses) == 0 {
 		result.Success = false
 		result.ErrorMessage = "DNS lookup returned no results"
+		result.ErrorCategory = CategoryDNSNoRecords
 		return result
 	}
 
@@ -114,6 +118,7 @@ func performDNSCheck(m *client.MonitorAssignment) *Result {
 		if !found {
 			result.Success = false
 			result.ErrorMessage = fmt.Sprintf("expected DNS host %s not found in results", m.ExpectedDNSHost)
+			result.ErrorCategory = CategoryDNSMismatch
 			return result
 		}
 	}
@@ -0,0 +1,249 @@
+package checker
+
+import (
+	"appoller/client"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// flowVariablePattern matches {{name}} placeholders in step templates.
+var flowVariablePattern = regexp.MustCompile(`\{\{\s*([A-Za-z0-9_.-]+)\s*\}\}`)
+
+func init() {
+	Register("api_flow", CheckerFunc(performAPIFlowCheck))
+}
+
+// performAPIFlowCheck runs the monitor's steps in order, carrying extracted
+// variables and cookies from one step to the next. The check stops at the
+// first failing step.
+func performAPIFlowCheck(ctx context.Context, m *client.MonitorAssignment, opts Options) *Result {
+	result := NewResult(m)
+
+	if len(m.Steps) == 0 {
+		result.Success = false
+		result.ErrorMessage = "api_flow monitor has no steps"
+		result.ErrorCategory = CategoryInvalidConfig
+		return result
+	}
+
+	jar, _ := cookiejar.New(nil)
+	httpClient, err := newHTTPClient(m, opts, jar)
+	if err != nil {
+		result.Success = false
+		result.ErrorMessage = err.Error()
+		result.ErrorCategory = CategoryInvalidConfig
+		return result
+	}
+	vars := make(map[string]string)
+
+	for i := range m.Steps {
+		step := &m.Steps[i]
+		name := step.Name
+		if name == "" {
+			name = fmt.Sprintf("step %d", i+1)
+		}
+
+		sr, resp := runFlowStep(ctx, httpClient, jar, m, step, vars)
+		sr.Name = name
+		result.Steps = append(result.Steps, sr)
+		result.ResponseTimeMs += sr.ResponseTimeMs
+		result.StatusCode = sr.StatusCode
+		if resp != nil {
+			result.ResponseBody = truncateBody(resp.Body)
+		}
+
+		if !sr.Success {
+			result.Success = false
+			result.FailedStep = i + 1
+			result.ErrorCategory = sr.ErrorCategory
+			result.ErrorMessage = fmt.Sprintf("step %d (%s) failed: %s", i+1, name, sr.ErrorMessage)
+			return result
+		}
+	}
+
+	result.Success = true
+	return result
+}
+
+// runFlowStep executes one step and applies its extractions to vars.
+func runFlowStep(ctx context.Context, httpClient *http.Client, jar http.CookieJar, m *client.MonitorAssignment,
+	step *client.FlowStep, vars map[string]string) (client.StepResult, *httpResponse) {
+
+	sr := client.StepResult{}
+
+	spec, err := buildFlowRequest(m, step, vars)
+	if err != nil {
+		sr.ErrorMessage = err.Error()
+		sr.ErrorCategory = CategoryInvalidConfig
+		return sr, nil
+	}
+
+	readLimit := int64(maxResponseBodySize)
+	if len(step.JSONAssertions) > 0 || len(step.Extract) > 0 {
+		readLimit = maxAssertionBodySize
+	}
+
+	resp, err := doHTTPRequest(ctx, httpClient, spec, readLimit)
+	if resp != nil {
+		sr.StatusCode = resp.StatusCode
+		sr.ResponseTimeMs = resp.ResponseTimeMs
+		sr.Timings = resp.Timings
+	}
+	if err != nil {
+		sr.ErrorMessage = err.Error()
+		sr.ErrorCategory = CategoryInvalidConfig
+		if resp != nil {
+			sr.ErrorCategory = resp.ErrorCategory
+		}
+		return sr, resp
+	}
+
+	if msg, category := validateHTTPResponse(resp, httpExpectations{
+		StatusCode:     step.ExpectedStatusCode,
+		Contains:       step.ExpectedResponseContains,
+		JSONAssertions: step.JSONAssertions,
+	}); msg != "" {
+		sr.ErrorMessage = msg
+		sr.ErrorCategory = category
+		return sr, resp
+	}
+
+	for _, ex := range step.Extract {
+		value, err := extractFlowValue(resp, jar, spec.URL, ex)
+		if err != nil {
+			sr.ErrorMessage = fmt.Sprintf("extract %s: %v", ex.Variable, err)
+			sr.ErrorCategory = CategoryExtractionFailed
+			return sr, resp
+		}
+		vars[ex.Variable] = value
+	}
+
+	sr.Success = true
+	return sr, resp
+}
+
+// buildFlowRequest renders a step into a request spec. Monitor-level headers
+// and auth apply to every step unless the step overrides them.
+func buildFlowRequest(m *client.MonitorAssignment, step *client.FlowStep, vars map[string]string) (httpRequestSpec, error) {
+	spec := httpRequestSpec{Method: step.HTTPMethod}
+
+	var err error
+	if spec.URL, err = substituteFlowVars(step.URL, vars); err != nil {
+		return spec, fmt.Errorf("url: %w", err)
+	}
+	if step.RequestBody != nil {
+		if spec.Body, err = substituteFlowVars(*step.RequestBody, vars); err != nil {
+			return spec, fmt.Errorf("body: %w", err)
+		}
+	}
+
+	spec.Headers = make(map[string]string, len(m.Headers)+len(step.Headers))
+	for k, v := range m.Headers {
+		spec.Headers[k] = v
+	}
+	for k, v := range step.Headers {
+		spec.Headers[k] = v
+	}
+	for k, v := range spec.Headers {
+		if spec.Headers[k], err = substituteFlowVars(v, vars); err != nil {
+			return spec, fmt.Errorf("header %s: %w", k, err)
+		}
+	}
+
+	auth := step.Auth
+	if auth == nil {
+		auth = m.Auth
+	}
+	if auth != nil {
+		rendered := *auth
+		if rendered.Username, err = substituteFlowVars(auth.Username, vars); err != nil {
+			return spec, fmt.Errorf("auth: %w", err)
+		}
+		if rendered.Password, err = substituteFlowVars(auth.Password, vars); err != nil {
+			return spec, fmt.Errorf("auth: %w", err)
+		}
+		if rendered.Token, err = substituteFlowVars(auth.Token, vars); err != nil {
+			return spec, fmt.Errorf("auth: %w", err)
+		}
+		spec.Auth = &rendered
+	}
+
+	return spec, nil
+}
+
+// substituteFlowVars replaces {{name}} placeholders with extracted values.
+func substituteFlowVars(s string, vars map[string]string) (string, error) {
+	var missing []string
+	out := flowVariablePattern.ReplaceAllStringFunc(s, func(match string) string {
+		name := flowVariablePattern.FindStringSubmatch(match)[1]
+		value, ok := vars[name]
+		if !ok {
+			missing = append(missing, name)
+			return match
+		}
+		return value
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("undefined variable %s", strings.Join(missing, ", "))
+	}
+	return out, nil
+}
+
+// extractFlowValue pulls a single value out of a step response.
+func extractFlowValue(resp *httpResponse, jar http.CookieJar, rawURL string, ex client.FlowExtraction) (string, error) {
+	switch ex.Source {
+	case "json", "":
+		doc, err := decodeJSONBody(resp.Body)
+		if err != nil {
+			return "", fmt.Errorf("response is not valid JSON: %v", err)
+		}
+		value, found, err := lookupJSONPath(doc, ex.Expression)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return "", fmt.Errorf("%s not found in response", ex.Expression)
+		}
+		return jsonValueString(value), nil
+	case "header":
+		value := resp.Header.Get(ex.Expression)
+		if value == "" {
+			return "", fmt.Errorf("header %s not present", ex.Expression)
+		}
+		return value, nil
+	case "cookie":
+		for _, c := range resp.Cookies {
+			if c.Name == ex.Expression {
+				return c.Value, nil
+			}
+		}
+		if u, err := url.Parse(rawURL); err == nil {
+			for _, c := range jar.Cookies(u) {
+				if c.Name == ex.Expression {
+					return c.Value, nil
+				}
+			}
+		}
+		return "", fmt.Errorf("cookie %s not set", ex.Expression)
+	case "regex":
+		re, err := regexp.Compile(ex.Expression)
+		if err != nil {
+			return "", fmt.Errorf("invalid regular expression: %v", err)
+		}
+		match := re.FindSubmatch(resp.Body)
+		if match == nil {
+			return "", fmt.Errorf("pattern %s did not match response", ex.Expression)
+		}
+		if len(match) > 1 {
+			return string(match[1]), nil
+		}
+		return string(match[0]), nil
+	default:
+		return "", fmt.Errorf("unknown extraction source %q", ex.Source)
+	}
+}
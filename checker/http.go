@@ -2,71 +2,163 @@ package checker
 
 import (
 	"appoller/client"
-	"crypto/tls"
+	"context"
 	"encoding/base64"
 	"fmt"
 	"io"
-	"net"
 	"net/http"
+	"net/http/httptrace"
 	"strings"
 	"time"
 )
 
-func performHTTPCheck(m *client.MonitorAssignment, tlsInsecure bool) *Result {
-	result := &Result{
-		MonitorUUID: m.UUID,
-		Subdomain:   m.Subdomain,
-		Location:    m.Location,
-		CheckedAt:   time.Now().UTC(),
+// httpRequestSpec describes a single HTTP request made by a check.
+type httpRequestSpec struct {
+	Method  string
+	URL     string
+	Headers map[string]string
+	Body    string
+	Auth    *client.MonitorAuth
+}
+
+// httpResponse is what a check keeps from an executed request.
+type httpResponse struct {
+	StatusCode     int
+	Header         http.Header
+	Cookies        []*http.Cookie
+	Body           []byte
+	ResponseTimeMs int64
+	Timings        *client.PhaseTimings
+	ErrorCategory  string // set when the request itself failed
+}
+
+// httpExpectations are the validations applied to an httpResponse.
+type httpExpectations struct {
+	StatusCode     int
+	Contains       *string
+	JSONAssertions []client.JSONAssertion
+}
+
+func init() {
+	Register("http", CheckerFunc(performHTTPCheck))
+	Register("api", CheckerFunc(performHTTPCheck))
+}
+
+func performHTTPCheck(ctx context.Context, m *client.MonitorAssignment, opts Options) *Result {
+	result := NewResult(m)
+
+	httpClient, err := newHTTPClient(m, opts, nil)
+	if err != nil {
+		result.Success = false
+		result.ErrorMessage = err.Error()
+		result.ErrorCategory = CategoryInvalidConfig
+		return result
 	}
 
+	spec := httpRequestSpec{
+		Method:  m.HTTPMethod,
+		URL:     m.URL,
+		Headers: m.Headers,
+		Auth:    m.Auth,
+	}
+	if m.RequestBody != nil {
+		spec.Body = *m.RequestBody
+	}
+
+	// JSON assertions need the whole document; only a snippet is reported.
+	readLimit := int64(maxResponseBodySize)
+	if len(m.JSONAssertions) > 0 {
+		readLimit = maxAssertionBodySize
+	}
+
+	resp, err := doHTTPRequest(ctx, httpClient, spec, readLimit)
+	if resp != nil {
+		result.StatusCode = resp.StatusCode
+		result.ResponseTimeMs = resp.ResponseTimeMs
+		result.Timings = resp.Timings
+		result.ResponseBody = truncateBody(resp.Body)
+	}
+	if err != nil {
+		result.Success = false
+		result.ErrorMessage = err.Error()
+		result.ErrorCategory = CategoryInvalidConfig
+		if resp != nil {
+			result.ErrorCategory = resp.ErrorCategory
+		}
+		return result
+	}
+
+	if msg, category := validateHTTPResponse(resp, httpExpectations{
+		StatusCode:     m.ExpectedStatusCode,
+		Contains:       m.ExpectedResponseContains,
+		JSONAssertions: m.JSONAssertions,
+	}); msg != "" {
+		result.Success = false
+		result.ErrorMessage = msg
+		result.ErrorCategory = category
+		return result
+	}
+
+	result.Success = true
+	return result
+}
+
+func httpTimeout(m *client.MonitorAssignment) time.Duration {
 	timeout := time.Duration(m.TimeoutSeconds) * time.Second
 	if timeout == 0 {
 		timeout = 30 * time.Second
 	}
+	return timeout
+}
 
-	transport := &http.Transport{
-		TLSClientConfig: &tls.Config{
-			InsecureSkipVerify: tlsInsecure,
-		},
-		DialContext: (&net.Dialer{
-			Timeout:   timeout,
-			KeepAlive: 30 * time.Second,
-		}).DialContext,
-		TLSHandshakeTimeout:   10 * time.Second,
-		ResponseHeaderTimeout: timeout,
+// newHTTPClient builds the client used for a check on top of a shared
+// transport. jar may be nil.
+func newHTTPClient(m *client.MonitorAssignment, opts Options, jar http.CookieJar) (*http.Client, error) {
+	key, err := transportKeyFor(m, opts)
+	if err != nil {
+		return nil, err
+	}
+	transport, err := sharedTransports.get(key)
+	if err != nil {
+		return nil, err
 	}
 
-	httpClient := &http.Client{
-		Timeout:   timeout,
+	return &http.Client{
+		Timeout:   httpTimeout(m),
 		Transport: transport,
+		Jar:       jar,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			if len(via) >= 10 {
-				return fmt.Errorf("too many redirects")
+				return errTooManyRedirects
 			}
 			return nil
 		},
-	}
+	}, nil
+}
 
+// doHTTPRequest executes spec and reads up to readLimit bytes of the body.
+// On transport errors, including failures while reading the body, the
+// returned response is non-nil and carries the timings gathered so far and
+// the error category. A nil response with an error means the request could
+// not be built.
+func doHTTPRequest(ctx context.Context, httpClient *http.Client, spec httpRequestSpec, readLimit int64) (*httpResponse, error) {
 	var bodyReader io.Reader
-	if m.RequestBody != nil && *m.RequestBody != "" {
-		bodyReader = strings.NewReader(*m.RequestBody)
+	if spec.Body != "" {
+		bodyReader = strings.NewReader(spec.Body)
 	}
 
-	method := m.HTTPMethod
+	method := spec.Method
 	if method == "" {
 		method = "GET"
 	}
 
-	req, err := http.NewRequest(method, m.URL, bodyReader)
+	req, err := http.NewRequestWithContext(ctx, method, spec.URL, bodyReader)
 	if err != nil {
-		result.Success = false
-		result.ErrorMessage = fmt.Sprintf("failed to create request: %v", err)
-		return result
+		return nil, fmt.Errorf("failed to create request: %v", err)
 	}
 
 	// Headers
-	for key, value := range m.Headers {
+	for key, value := range spec.Headers {
 		req.Header.Set(key, value)
 	}
 	if req.Header.Get("User-Agent") == "" {
@@ -74,56 +166,93 @@ func performHTTPCheck(m *client.MonitorAssignment, tlsInsecure bool) *Result {
 	}
 
 	// Authentication
-	if m.Auth != nil {
-		switch m.Auth.Type {
+	if spec.Auth != nil {
+		switch spec.Auth.Type {
 		case "basic":
 			auth := base64.StdEncoding.EncodeToString(
-				[]byte(m.Auth.Username + ":" + m.Auth.Password),
+				[]byte(spec.Auth.Username + ":" + spec.Auth.Password),
 			)
 			req.Header.Set("Authorization", "Basic "+auth)
 		case "bearer":
-			req.Header.Set("Authorization", "Bearer "+m.Auth.Token)
+			req.Header.Set("Authorization", "Bearer "+spec.Auth.Token)
 		}
 	}
 
+	tracer := newPhaseTracer()
+	req = req.WithContext(httptrace.WithClientTrace(req.Context(), tracer.clientTrace()))
+
+	out := &httpResponse{}
+
 	start := time.Now()
 	resp, err := httpClient.Do(req)
 	elapsed := time.Since(start)
-	result.ResponseTimeMs = elapsed.Milliseconds()
+	out.ResponseTimeMs = elapsed.Milliseconds()
 
 	if err != nil {
-		result.Success = false
-		result.ErrorMessage = fmt.Sprintf("request failed: %v", err)
-		return result
+		out.Timings = tracer.timings(time.Now())
+		out.ErrorCategory = classifyTransportError(err, tracer)
+		return out, fmt.Errorf("request failed: %v", err)
 	}
 	defer resp.Body.Close()
 
-	result.StatusCode = resp.StatusCode
+	out.StatusCode = resp.StatusCode
+	out.Header = resp.Header
+	out.Cookies = resp.Cookies()
+
+	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, readLimit))
+	out.Timings = tracer.timings(time.Now())
+	out.Body = bodyBytes
+	if err != nil {
+		out.ErrorCategory = classifyTransportError(err, tracer)
+		return out, fmt.Errorf("failed to read response body: %v", err)
+	}
+
+	return out, nil
+}
 
-	bodyBytes, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBodySize))
-	if err == nil {
-		result.ResponseBody = string(bodyBytes)
+// classifyTransportError categorises a failed request or body read,
+// attributing timeouts to the phase that was in progress.
+func classifyTransportError(err error, tracer *phaseTracer) string {
+	category := classifyError(err)
+	switch category {
+	case CategoryTimeout, CategoryConnectTimeout, CategoryReadTimeout:
+		return tracer.timeoutCategory()
 	}
+	return category
+}
 
-	expectedStatus := m.ExpectedStatusCode
+// validateHTTPResponse returns a failure message and its category, or "" if
+// resp meets all expectations.
+func validateHTTPResponse(resp *httpResponse, exp httpExpectations) (string, string) {
+	expectedStatus := exp.StatusCode
 	if expectedStatus == 0 {
 		expectedStatus = 200
 	}
 
 	if resp.StatusCode != expectedStatus {
-		result.Success = false
-		result.ErrorMessage = fmt.Sprintf("unexpected status code: got %d, expected %d", resp.StatusCode, expectedStatus)
-		return result
+		return fmt.Sprintf("unexpected status code: got %d, expected %d", resp.StatusCode, expectedStatus),
+			CategoryHTTPStatusMismatch
 	}
 
-	if m.ExpectedResponseContains != nil && *m.ExpectedResponseContains != "" {
-		if !strings.Contains(result.ResponseBody, *m.ExpectedResponseContains) {
-			result.Success = false
-			result.ErrorMessage = fmt.Sprintf("response does not contain expected string: %s", *m.ExpectedResponseContains)
-			return result
+	if exp.Contains != nil && *exp.Contains != "" {
+		if !strings.Contains(truncateBody(resp.Body), *exp.Contains) {
+			return fmt.Sprintf("response does not contain expected string: %s", *exp.Contains), CategoryBodyAssertion
 		}
 	}
 
-	result.Success = true
-	return result
+	if len(exp.JSONAssertions) > 0 {
+		if failures := evaluateJSONAssertions(resp.Body, exp.JSONAssertions); len(failures) > 0 {
+			return "JSON assertion failed: " + strings.Join(failures, "; "), CategoryBodyAssertion
+		}
+	}
+
+	return "", ""
+}
+
+// truncateBody converts a response body to the snippet stored on a Result.
+func truncateBody(body []byte) string {
+	if len(body) > maxResponseBodySize {
+		body = body[:maxResponseBodySize]
+	}
+	return string(body)
 }
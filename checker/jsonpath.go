@@ -0,0 +1,101 @@
+package checker
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// lookupJSONPath resolves a simple JSONPath expression against a decoded JSON
+// document. Supported syntax is the root ($), dotted member access (.name),
+// bracketed member access (['name'] or ["name"]) and array indexes ([0],
+// negative indexes count from the end). The leading $ is optional.
+// It reports whether the path exists in the document.
+func lookupJSONPath(doc interface{}, path string) (interface{}, bool, error) {
+	segments, err := parseJSONPath(path)
+	if err != nil {
+		return nil, false, err
+	}
+
+	cur := doc
+	for _, seg := range segments {
+		switch node := cur.(type) {
+		case map[string]interface{}:
+			if seg.isIndex {
+				return nil, false, nil
+			}
+			v, ok := node[seg.key]
+			if !ok {
+				return nil, false, nil
+			}
+			cur = v
+		case []interface{}:
+			if !seg.isIndex {
+				return nil, false, nil
+			}
+			idx := seg.index
+			if idx < 0 {
+				idx += len(node)
+			}
+			if idx < 0 || idx >= len(node) {
+				return nil, false, nil
+			}
+			cur = node[idx]
+		default:
+			return nil, false, nil
+		}
+	}
+	return cur, true, nil
+}
+
+type jsonPathSegment struct {
+	key     string
+	index   int
+	isIndex bool
+}
+
+func parseJSONPath(path string) ([]jsonPathSegment, error) {
+	p := strings.TrimSpace(path)
+	p = strings.TrimPrefix(p, "$")
+
+	var segments []jsonPathSegment
+	for len(p) > 0 {
+		switch p[0] {
+		case '.':
+			p = p[1:]
+			end := strings.IndexAny(p, ".[")
+			if end == -1 {
+				end = len(p)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("invalid JSON path %q: empty member name", path)
+			}
+			segments = append(segments, jsonPathSegment{key: p[:end]})
+			p = p[end:]
+		case '[':
+			end := strings.IndexByte(p, ']')
+			if end == -1 {
+				return nil, fmt.Errorf("invalid JSON path %q: unterminated bracket", path)
+			}
+			inner := strings.TrimSpace(p[1:end])
+			p = p[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				segments = append(segments, jsonPathSegment{key: inner[1 : len(inner)-1]})
+				continue
+			}
+			n, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid JSON path %q: bad index %q", path, inner)
+			}
+			segments = append(segments, jsonPathSegment{index: n, isIndex: true})
+		default:
+			// Allow a bare first member, e.g. "status" or "components.db".
+			if len(segments) == 0 && !strings.HasPrefix(strings.TrimSpace(path), "$") {
+				p = "." + p
+				continue
+			}
+			return nil, fmt.Errorf("invalid JSON path %q: unexpected %q", path, p[0])
+		}
+	}
+	return segments, nil
+}
@@ -0,0 +1,76 @@
+package checker
+
+import (
+	"appoller/client"
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Checker executes checks for a monitor type. Implementations must stop
+// promptly once ctx is done.
+type Checker interface {
+	Check(ctx context.Context, m *client.MonitorAssignment, opts Options) *Result
+}
+
+// CheckerFunc adapts an ordinary function to the Checker interface.
+type CheckerFunc func(ctx context.Context, m *client.MonitorAssignment, opts Options) *Result
+
+// Check calls f(ctx, m, opts).
+func (f CheckerFunc) Check(ctx context.Context, m *client.MonitorAssignment, opts Options) *Result {
+	return f(ctx, m, opts)
+}
+
+// Options carries poller-wide settings to every checker.
+type Options struct {
+	TLSInsecure  bool          // skip TLS verification for HTTP checks
+	ConfirmDelay time.Duration // pause between failure confirmation attempts
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Checker)
+)
+
+// Register makes a checker available for the given monitor type. It is
+// intended to be called from an init function; in-house check types live in
+// their own package and are compiled in with a blank import in cmd/main.go.
+// Register panics if the type is empty, already registered, or c is nil.
+func Register(monitorType string, c Checker) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if monitorType == "" {
+		panic("checker: Register with empty monitor type")
+	}
+	if c == nil {
+		panic("checker: Register checker is nil for " + monitorType)
+	}
+	if _, dup := registry[monitorType]; dup {
+		panic(fmt.Sprintf("checker: Register called twice for %s", monitorType))
+	}
+	registry[monitorType] = c
+}
+
+// Lookup returns the checker registered for a monitor type.
+func Lookup(monitorType string) (Checker, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	c, ok := registry[monitorType]
+	return c, ok
+}
+
+// Types returns the registered monitor types in sorted order.
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	types := make([]string, 0, len(registry))
+	for t := range registry {
+		types = append(types, t)
+	}
+	sort.Strings(types)
+	return types
+}
@@ -2,6 +2,7 @@ package checker
 
 import (
 	"appoller/client"
+	"context"
 	"crypto/tls"
 	"fmt"
 	"net"
@@ -10,24 +11,25 @@ import (
 	"time"
 )
 
-func performSSLCheck(m *client.MonitorAssignment) *Result {
-	result := &Result{
-		MonitorUUID: m.UUID,
-		Subdomain:   m.Subdomain,
-		Location:    m.Location,
-		CheckedAt:   time.Now().UTC(),
-	}
+func init() {
+	Register("ssl", CheckerFunc(performSSLCheck))
+}
+
+func performSSLCheck(ctx context.Context, m *client.MonitorAssignment, opts Options) *Result {
+	result := NewResult(m)
 
 	parsedURL, err := url.Parse(m.URL)
 	if err != nil {
 		result.Success = false
 		result.ErrorMessage = fmt.Sprintf("failed to parse URL: %v", err)
+		result.ErrorCategory = CategoryInvalidConfig
 		return result
 	}
 
 	if parsedURL.Scheme != "https" {
 		result.Success = false
 		result.ErrorMessage = "URL is not HTTPS"
+		result.ErrorCategory = CategoryInvalidConfig
 		return result
 	}
 
@@ -41,21 +43,33 @@ func performSSLCheck(m *client.MonitorAssignment) *Result {
 		timeout = 30 * time.Second
 	}
 
-	dialer := &net.Dialer{Timeout: timeout}
-	conn, err := tls.DialWithDialer(dialer, "tcp", host, &tls.Config{
-		InsecureSkipVerify: false,
-	})
+	dialer := &tls.Dialer{
+		NetDialer: &net.Dialer{Timeout: timeout},
+		Config: &tls.Config{
+			InsecureSkipVerify: false,
+		},
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	rawConn, err := dialer.DialContext(ctx, "tcp", host)
 	if err != nil {
 		result.Success = false
 		result.ErrorMessage = fmt.Sprintf("TLS connection failed: %v", err)
+		result.ErrorCategory = classifyError(err)
+		if result.ErrorCategory == CategoryReadTimeout {
+			// Reads on a fresh TLS connection only happen during the handshake.
+			result.ErrorCategory = CategoryTLSHandshake
+		}
 		return result
 	}
+	conn := rawConn.(*tls.Conn)
 	defer conn.Close()
 
 	certs := conn.ConnectionState().PeerCertificates
 	if len(certs) == 0 {
 		result.Success = false
 		result.ErrorMessage = "no certificates found"
+		result.ErrorCategory = CategoryCertInvalid
 		return result
 	}
 
@@ -77,6 +91,7 @@ func performSSLCheck(m *client.MonitorAssignment) *Result {
 		result.Success = false
 		result.ErrorMessage = fmt.Sprintf("SSL certificate expires in %d days (threshold: %d days)",
 			daysRemaining, alertDays)
+		result.ErrorCategory = CategoryCertExpiring
 	}
 
 	return result
@@ -2,19 +2,20 @@ package checker
 
 import (
 	"appoller/client"
+	"context"
 	"fmt"
 	"net"
+	"strconv"
 	"strings"
 	"time"
 )
 
-func performTCPCheck(m *client.MonitorAssignment) *Result {
-	result := &Result{
-		MonitorUUID: m.UUID,
-		Subdomain:   m.Subdomain,
-		Location:    m.Location,
-		CheckedAt:   time.Now().UTC(),
-	}
+func init() {
+	Register("tcp", CheckerFunc(performTCPCheck))
+}
+
+func performTCPCheck(ctx context.Context, m *client.MonitorAssignment, opts Options) *Result {
+	result := NewResult(m)
 
 	host := m.URL
 	host = strings.TrimPrefix(host, "http://")
@@ -36,10 +37,11 @@ func performTCPCheck(m *client.MonitorAssignment) *Result {
 	if port == 0 {
 		result.Success = false
 		result.ErrorMessage = "TCP port not specified"
+		result.ErrorCategory = CategoryInvalidConfig
 		return result
 	}
 
-	address := fmt.Sprintf("%s:%d", host, port)
+	address := net.JoinHostPort(host, strconv.Itoa(port))
 
 	timeout := time.Duration(m.TimeoutSeconds) * time.Second
 	if timeout == 0 {
@@ -49,13 +51,17 @@ func performTCPCheck(m *client.MonitorAssignment) *Result {
 	dialer := net.Dialer{Timeout: timeout}
 
 	start := time.Now()
-	conn, err := dialer.Dial("tcp", address)
+	conn, err := dialer.DialContext(ctx, "tcp", address)
 	elapsed := time.Since(start)
 	result.ResponseTimeMs = elapsed.Milliseconds()
 
 	if err != nil {
 		result.Success = false
 		result.ErrorMessage = fmt.Sprintf("TCP connection failed: %v", err)
+		result.ErrorCategory = classifyError(err)
+		if result.ErrorCategory == CategoryTimeout {
+			result.ErrorCategory = CategoryConnectTimeout
+		}
 		return result
 	}
 	defer conn.Close()
@@ -0,0 +1,156 @@
+package checker
+
+import (
+	"appoller/client"
+	"crypto/tls"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// phaseTracer records per-phase timings for an HTTP request via httptrace.
+// Durations are accumulated across redirects; the transfer phase covers only
+// the final response body.
+type phaseTracer struct {
+	mu sync.Mutex
+
+	start        time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	gotConn      time.Time
+	wroteRequest time.Time
+	firstByte    time.Time
+
+	dns     time.Duration
+	connect time.Duration
+	tls     time.Duration
+	ttfb    time.Duration
+
+	reused bool // last connection came from the keep-alive pool
+
+	// Phases in progress, used to attribute timeouts.
+	connPending bool
+	dnsPending  bool
+	tlsPending  bool
+}
+
+func newPhaseTracer() *phaseTracer {
+	return &phaseTracer{start: time.Now()}
+}
+
+// clientTrace returns the httptrace hooks that feed this tracer.
+func (t *phaseTracer) clientTrace() *httptrace.ClientTrace {
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			t.mu.Lock()
+			t.connPending = true
+			t.mu.Unlock()
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			t.mu.Lock()
+			t.dnsStart = time.Now()
+			t.dnsPending = true
+			t.mu.Unlock()
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			t.mu.Lock()
+			t.dnsPending = false
+			if !t.dnsStart.IsZero() {
+				t.dns += time.Since(t.dnsStart)
+			}
+			t.mu.Unlock()
+		},
+		ConnectStart: func(network, addr string) {
+			t.mu.Lock()
+			// Dual-stack dialing may start several attempts; time from the first.
+			if t.connectStart.IsZero() {
+				t.connectStart = time.Now()
+			}
+			t.mu.Unlock()
+		},
+		ConnectDone: func(network, addr string, err error) {
+			t.mu.Lock()
+			if err == nil && !t.connectStart.IsZero() {
+				t.connect += time.Since(t.connectStart)
+				t.connectStart = time.Time{}
+			}
+			t.mu.Unlock()
+		},
+		TLSHandshakeStart: func() {
+			t.mu.Lock()
+			t.tlsStart = time.Now()
+			t.tlsPending = true
+			t.mu.Unlock()
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			t.mu.Lock()
+			t.tlsPending = false
+			if !t.tlsStart.IsZero() {
+				t.tls += time.Since(t.tlsStart)
+			}
+			t.mu.Unlock()
+		},
+		GotConn: func(info httptrace.GotConnInfo) {
+			t.mu.Lock()
+			t.gotConn = time.Now()
+			t.reused = info.Reused
+			t.connPending = false
+			t.mu.Unlock()
+		},
+		WroteRequest: func(httptrace.WroteRequestInfo) {
+			t.mu.Lock()
+			t.wroteRequest = time.Now()
+			t.mu.Unlock()
+		},
+		GotFirstResponseByte: func() {
+			t.mu.Lock()
+			t.firstByte = time.Now()
+			sent := t.wroteRequest
+			if sent.IsZero() {
+				sent = t.gotConn
+			}
+			if !sent.IsZero() {
+				t.ttfb += t.firstByte.Sub(sent)
+			}
+			t.mu.Unlock()
+		},
+	}
+}
+
+// timeoutCategory attributes a request timeout to the phase in progress.
+func (t *phaseTracer) timeoutCategory() string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case t.dnsPending:
+		return CategoryDNSTimeout
+	case t.tlsPending:
+		return CategoryTLSHandshake
+	case t.connPending:
+		return CategoryConnectTimeout
+	default:
+		return CategoryReadTimeout
+	}
+}
+
+// timings returns the phase breakdown, treating end as the moment the
+// response body finished reading (or the request failed).
+func (t *phaseTracer) timings(end time.Time) *client.PhaseTimings {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	pt := &client.PhaseTimings{
+		DNSMs:     t.dns.Milliseconds(),
+		ConnectMs: t.connect.Milliseconds(),
+		TLSMs:     t.tls.Milliseconds(),
+		TTFBMs:    t.ttfb.Milliseconds(),
+		TotalMs:   end.Sub(t.start).Milliseconds(),
+		Reused:    t.reused,
+	}
+	if !t.firstByte.IsZero() {
+		pt.TransferMs = end.Sub(t.firstByte).Milliseconds()
+	}
+	return pt
+}
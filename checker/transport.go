@@ -0,0 +1,143 @@
+package checker
+
+import (
+	"appoller/client"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	// transportIdleTTL is how long an unused shared transport is kept.
+	transportIdleTTL = 10 * time.Minute
+	// transportSweepInterval is how often unused transports are looked for.
+	transportSweepInterval = time.Minute
+)
+
+// Connection modes for HTTP monitors.
+const (
+	ConnectionModeFresh = "fresh" // new connection, full DNS/TCP/TLS cost on every run (default)
+	ConnectionModeReuse = "reuse" // keep-alive connections shared between runs
+)
+
+// transportKey identifies checks whose connections can be shared.
+type transportKey struct {
+	tlsInsecure bool
+	proxyURL    string
+	fresh       bool
+}
+
+type pooledTransport struct {
+	transport *http.Transport
+	lastUsed  time.Time
+}
+
+// transportPool hands out shared http.Transports so HTTP checks don't build a
+// transport per check, and checks in reuse mode keep connections and
+// handshakes across runs. Transports unused for transportIdleTTL are closed
+// and dropped.
+type transportPool struct {
+	mu         sync.Mutex
+	transports map[transportKey]*pooledTransport
+	lastSweep  time.Time
+}
+
+var sharedTransports = &transportPool{
+	transports: make(map[transportKey]*pooledTransport),
+}
+
+// get returns the shared transport for key, creating it on first use.
+func (p *transportPool) get(key transportKey) (*http.Transport, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(p.lastSweep) >= transportSweepInterval {
+		p.sweep(now)
+	}
+
+	if pt, ok := p.transports[key]; ok {
+		pt.lastUsed = now
+		return pt.transport, nil
+	}
+
+	t, err := newTransport(key)
+	if err != nil {
+		return nil, err
+	}
+	p.transports[key] = &pooledTransport{transport: t, lastUsed: now}
+	return t, nil
+}
+
+// sweep closes transports that have not been used recently. Callers hold p.mu.
+func (p *transportPool) sweep(now time.Time) {
+	p.lastSweep = now
+	for key, pt := range p.transports {
+		if now.Sub(pt.lastUsed) >= transportIdleTTL {
+			pt.transport.CloseIdleConnections()
+			delete(p.transports, key)
+		}
+	}
+}
+
+// closeIdle closes idle connections on every shared transport.
+func (p *transportPool) closeIdle() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for _, pt := range p.transports {
+		pt.transport.CloseIdleConnections()
+	}
+}
+
+// CloseIdleConnections releases idle keep-alive connections held for HTTP
+// checks. Call it on shutdown.
+func CloseIdleConnections() {
+	sharedTransports.closeIdle()
+}
+
+func newTransport(key transportKey) (*http.Transport, error) {
+	t := &http.Transport{
+		TLSClientConfig: &tls.Config{
+			InsecureSkipVerify: key.tlsInsecure,
+		},
+		// Per-check timeouts come from the request context and client timeout.
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		TLSHandshakeTimeout: 10 * time.Second,
+		DisableKeepAlives:   key.fresh,
+		MaxIdleConns:        1000,
+		MaxIdleConnsPerHost: 4,
+		IdleConnTimeout:     90 * time.Second,
+	}
+
+	if key.proxyURL != "" {
+		u, err := url.Parse(key.proxyURL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL: %v", err)
+		}
+		t.Proxy = http.ProxyURL(u)
+	}
+	return t, nil
+}
+
+// transportKeyFor derives the transport key from a monitor's settings.
+func transportKeyFor(m *client.MonitorAssignment, opts Options) (transportKey, error) {
+	key := transportKey{
+		tlsInsecure: opts.TLSInsecure,
+		proxyURL:    m.ProxyURL,
+	}
+	switch m.ConnectionMode {
+	case "", ConnectionModeFresh:
+		key.fresh = true
+	case ConnectionModeReuse:
+	default:
+		return key, fmt.Errorf("unknown connection mode %q", m.ConnectionMode)
+	}
+	return key, nil
+}
@@ -3,11 +3,19 @@ package client
 import (
 	"appoller/config"
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync/atomic"
 	"time"
 )
 
@@ -16,23 +24,57 @@ type Client struct {
 	httpClient *http.Client
 	baseURL    string
 	token      string
+	retry      RetryPolicy
+	observer   Observer
+
+	compression  bool        // accept gzip responses and offer gzip requests at registration
+	gzipRequests atomic.Bool // the API accepted gzip request bodies at registration
+}
+
+// Observer receives telemetry about API requests.
+type Observer interface {
+	// ObserveAPICall is called after every attempt with an outcome of "ok",
+	// "http_4xx", "http_5xx" or "error".
+	ObserveAPICall(endpoint, outcome string)
+	// ObserveAPIRetry is called each time a failed attempt is retried.
+	ObserveAPIRetry(endpoint string)
 }
 
 // NewClient creates a new API client.
 func NewClient(cfg *config.Config) *Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DisableCompression = true // Accept-Encoding is set explicitly
 	return &Client{
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: transport,
+		},
+		baseURL:     cfg.APIURL + "/api/v1",
+		token:       cfg.PollerToken,
+		compression: cfg.APICompression,
+		retry: RetryPolicy{
+			MaxAttempts: cfg.APIMaxAttempts,
+			BaseDelay:   time.Duration(cfg.APIRetryBaseMs) * time.Millisecond,
+			MaxDelay:    time.Duration(cfg.APIRetryMaxDelay) * time.Second,
 		},
-		baseURL: cfg.APIURL + "/api/v1",
-		token:   cfg.PollerToken,
 	}
 }
 
+// SetObserver registers o to receive request telemetry. It must be called
+// before the client is used.
+func (c *Client) SetObserver(o Observer) {
+	c.observer = o
+}
+
 // RegisterRequest is sent when the poller starts.
 type RegisterRequest struct {
-	Hostname string `json:"hostname"`
-	Version  string `json:"version"`
+	Hostname     string   `json:"hostname"`
+	Version      string   `json:"version"`
+	MonitorTypes []string `json:"monitor_types,omitempty"` // check types this build can execute
+
+	// ContentEncodings lists the encodings the poller can send and receive.
+	// It is filled in by Register.
+	ContentEncodings []string `json:"content_encodings,omitempty"`
 }
 
 // RegisterResponse is returned from the register endpoint.
@@ -42,20 +84,45 @@ type RegisterResponse struct {
 	LocationKey  string `json:"location_key"`
 	LocationName string `json:"location_name"`
 	Type         string `json:"type"`
+
+	// ContentEncodings lists the request body encodings the API accepts.
+	// Older API versions omit it, and requests stay uncompressed.
+	ContentEncodings []string `json:"content_encodings,omitempty"`
+
+	// ResultFeatures lists optional result fields the results endpoint
+	// understands, such as FeatureSkipped. Older API versions omit it.
+	ResultFeatures []string `json:"result_features,omitempty"`
+}
+
+// FeatureSkipped means the API treats results with Skipped set as checks
+// that did not run, not as failures.
+const FeatureSkipped = "skipped"
+
+// AcceptsSkipped reports whether skipped results may be submitted.
+func (r *RegisterResponse) AcceptsSkipped() bool {
+	return slices.Contains(r.ResultFeatures, FeatureSkipped)
 }
 
 // Register registers this poller with the API.
-func (c *Client) Register(hostname, version string) (*RegisterResponse, error) {
-	body := RegisterRequest{
-		Hostname: hostname,
-		Version:  version,
+// Compression of later result submissions is negotiated here.
+func (c *Client) Register(ctx context.Context, req *RegisterRequest) (*RegisterResponse, error) {
+	body := *req
+	body.ContentEncodings = nil
+	if c.compression {
+		body.ContentEncodings = []string{encodingGzip}
 	}
 
 	resp := &RegisterResponse{}
-	err := c.doJSON("POST", "/poller/register", body, resp)
+	err := c.doJSON(ctx, &apiRequest{
+		method:     "POST",
+		path:       "/poller/register",
+		body:       &body,
+		idempotent: true,
+	}, resp)
 	if err != nil {
 		return nil, fmt.Errorf("register failed: %w", err)
 	}
+	c.gzipRequests.Store(c.compression && hasEncoding(resp.ContentEncodings, encodingGzip))
 	return resp, nil
 }
 
@@ -65,18 +132,30 @@ type HeartbeatRequest struct {
 	Status             string  `json:"status"`
 	CPUPercent         float64 `json:"cpu_percent"`
 	MemoryMB           int64   `json:"memory_mb"`
+	MemoryLimitMB      int64   `json:"memory_limit_mb,omitempty"` // cgroup limit; 0 when unlimited
+	CgroupMemoryMB     int64   `json:"cgroup_memory_mb,omitempty"`
+	Goroutines         int     `json:"goroutines,omitempty"`
 	QueueDepth         int     `json:"queue_depth"`
+	ChecksSkipped      int64   `json:"checks_skipped"`
+	WorkerUtilization  float64 `json:"worker_utilization"` // busy workers / pool size, 0..1
 	ChecksExecuted     int64   `json:"checks_executed"`
 	ChecksPerMinute    float64 `json:"checks_per_minute"`
 	AvgCheckDurationMs int64   `json:"avg_check_duration_ms"`
+	P95CheckDurationMs int64   `json:"p95_check_duration_ms"`
+	ErrorRate          float64 `json:"error_rate"`
 	Errors             int64   `json:"errors"`
 	UptimeSeconds      int64   `json:"uptime_seconds"`
 	Version            string  `json:"version"`
 }
 
 // Heartbeat sends a health report.
-func (c *Client) Heartbeat(req *HeartbeatRequest) error {
-	return c.doJSON("POST", "/poller/heartbeat", req, nil)
+func (c *Client) Heartbeat(ctx context.Context, req *HeartbeatRequest) error {
+	return c.doJSON(ctx, &apiRequest{
+		method:     "POST",
+		path:       "/poller/heartbeat",
+		body:       req,
+		idempotent: true,
+	}, nil)
 }
 
 // MonitorAssignment is a monitor the poller should check.
@@ -90,16 +169,24 @@ type MonitorAssignment struct {
 	RequestBody              *string           `json:"request_body,omitempty"`
 	Headers                  map[string]string `json:"headers,omitempty"`
 	Auth                     *MonitorAuth      `json:"auth,omitempty"`
+	ConnectionMode           string            `json:"connection_mode,omitempty"` // "fresh" (default) or "reuse"
+	ProxyURL                 string            `json:"proxy_url,omitempty"`
 	TimeoutSeconds           int               `json:"timeout_seconds"`
 	CheckIntervalSeconds     int               `json:"check_interval_seconds"`
+	CheckIntervalMs          int               `json:"check_interval_ms,omitempty"` // overrides CheckIntervalSeconds when set
+	ConfigRevision           string            `json:"config_revision,omitempty"`   // identifies this definition; derived by the poller if the API omits it
+	Tags                     []string          `json:"tags,omitempty"`              // matched by maintenance window scopes
+	DependsOn                []string          `json:"depends_on,omitempty"`        // parent monitor UUIDs; checks are suppressed while a parent is down
 	ExpectedStatusCode       int               `json:"expected_status_code"`
 	ExpectedResponseContains *string           `json:"expected_response_contains,omitempty"`
+	JSONAssertions           []JSONAssertion   `json:"json_assertions,omitempty"`
+	Steps                    []FlowStep        `json:"steps,omitempty"` // api_flow monitors
 	DNSRecordType            string            `json:"dns_record_type,omitempty"`
 	ExpectedDNSHost          string            `json:"expected_dns_host,omitempty"`
 	TCPPort                  int               `json:"tcp_port,omitempty"`
 	SSLCertMonitoring        bool              `json:"ssl_cert_monitoring"`
 	SSLCertExpiryAlertDays   *int              `json:"ssl_cert_expiry_alert_days,omitempty"`
-	FailureThreshold         int               `json:"failure_threshold"`
+	FailureThreshold         int               `json:"failure_threshold"` // attempts before a failure is reported
 	Location                 string            `json:"location"`
 }
 
@@ -111,34 +198,153 @@ type MonitorAuth struct {
 	Token    string `json:"token,omitempty"`
 }
 
-// MonitorsResponse is the response from the monitors endpoint.
+// JSONAssertion checks a value in a JSON response body, e.g.
+// {"path": "$.components.db.status", "operator": "equals", "value": "UP"}.
+type JSONAssertion struct {
+	Path     string `json:"path"`
+	Operator string `json:"operator"` // equals, not_equals, gt, gte, lt, lte, contains, matches, exists, not_exists
+	Value    string `json:"value,omitempty"`
+}
+
+// FlowStep is one request in an api_flow monitor. URL, headers, body and auth
+// may reference variables extracted by earlier steps as {{name}}.
+type FlowStep struct {
+	Name                     string            `json:"name"`
+	URL                      string            `json:"url"`
+	HTTPMethod               string            `json:"http_method"`
+	RequestBody              *string           `json:"request_body,omitempty"`
+	Headers                  map[string]string `json:"headers,omitempty"`
+	Auth                     *MonitorAuth      `json:"auth,omitempty"`
+	ExpectedStatusCode       int               `json:"expected_status_code"`
+	ExpectedResponseContains *string           `json:"expected_response_contains,omitempty"`
+	JSONAssertions           []JSONAssertion   `json:"json_assertions,omitempty"`
+	Extract                  []FlowExtraction  `json:"extract,omitempty"`
+}
+
+// FlowExtraction captures a value from a step response into a variable.
+type FlowExtraction struct {
+	Variable   string `json:"variable"`
+	Source     string `json:"source"`     // json, header, cookie, regex
+	Expression string `json:"expression"` // JSON path, header name, cookie name, or regex (first capture group)
+}
+
+// MonitorsResponse is the response from the monitors endpoint. When the
+// request names a revision the API may answer with a delta instead of the
+// full list.
 type MonitorsResponse struct {
 	Monitors []MonitorAssignment `json:"monitors"`
 	Total    int                 `json:"total"`
+	Revision int64               `json:"revision,omitempty"`
+	Delta    bool                `json:"delta,omitempty"`
+	Changed  []MonitorAssignment `json:"changed,omitempty"` // added or modified since the requested revision
+	Removed  []string            `json:"removed,omitempty"` // UUIDs removed since the requested revision
+}
+
+// MonitorSync identifies the monitor list the poller already holds.
+type MonitorSync struct {
+	ETag     string
+	Revision int64
 }
 
-// GetMonitors fetches monitors assigned to this poller's location.
-func (c *Client) GetMonitors() ([]MonitorAssignment, error) {
+// MonitorUpdate is the outcome of FetchMonitors. Exactly one of NotModified,
+// Full or a delta (Changed/Removed) applies.
+type MonitorUpdate struct {
+	NotModified bool
+	Full        bool
+	Monitors    []MonitorAssignment // complete list when Full
+	Changed     []MonitorAssignment
+	Removed     []string
+	Sync        MonitorSync // pass to the next FetchMonitors call
+}
+
+// FetchMonitors fetches monitors conditionally. The ETag from the previous
+// response is sent as If-None-Match and the revision as since_revision, so an
+// unchanged list costs a 304 and a changed one may arrive as a delta.
+func (c *Client) FetchMonitors(ctx context.Context, since MonitorSync) (*MonitorUpdate, error) {
+	req := &apiRequest{
+		method:      "GET",
+		path:        "/poller/monitors",
+		header:      http.Header{},
+		idempotent:  true,
+		response:    &apiResponse{},
+		maxResponse: maxMonitorsResponseBytes,
+	}
+	if since.ETag != "" {
+		req.header.Set("If-None-Match", since.ETag)
+	}
+	if since.Revision > 0 {
+		req.query = url.Values{"since_revision": {strconv.FormatInt(since.Revision, 10)}}
+	}
+
 	resp := &MonitorsResponse{}
-	err := c.doJSON("GET", "/poller/monitors", nil, resp)
-	if err != nil {
+	if err := c.doJSON(ctx, req, resp); err != nil {
 		return nil, fmt.Errorf("get monitors failed: %w", err)
 	}
-	return resp.Monitors, nil
+
+	if req.response.StatusCode == http.StatusNotModified {
+		return &MonitorUpdate{NotModified: true, Sync: since}, nil
+	}
+
+	update := &MonitorUpdate{
+		Sync: MonitorSync{
+			ETag:     req.response.Header.Get("ETag"),
+			Revision: resp.Revision,
+		},
+	}
+	if resp.Delta && since.Revision > 0 {
+		update.Changed = resp.Changed
+		update.Removed = resp.Removed
+	} else {
+		update.Full = true
+		update.Monitors = resp.Monitors
+	}
+	return update, nil
 }
 
 // CheckResult is a single check result to submit.
 type CheckResult struct {
-	MonitorUUID    string `json:"monitor_uuid"`
-	Subdomain      string `json:"subdomain"`
-	Location       string `json:"location"`
-	PollerUUID     string `json:"poller_uuid"`
-	CheckedAt      string `json:"checked_at"` // RFC3339
-	Success        bool   `json:"success"`
-	StatusCode     int    `json:"status_code,omitempty"`
-	ResponseTimeMs int64  `json:"response_time_ms"`
-	ErrorMessage   string `json:"error_message,omitempty"`
-	ResponseBody   string `json:"response_body,omitempty"`
+	MonitorUUID    string        `json:"monitor_uuid"`
+	Subdomain      string        `json:"subdomain"`
+	Location       string        `json:"location"`
+	PollerUUID     string        `json:"poller_uuid"`
+	CheckedAt      string        `json:"checked_at"` // RFC3339
+	Success        bool          `json:"success"`
+	StatusCode     int           `json:"status_code,omitempty"`
+	ResponseTimeMs int64         `json:"response_time_ms"`
+	ErrorMessage   string        `json:"error_message,omitempty"`
+	ErrorCategory  string        `json:"error_category,omitempty"` // e.g. connection_refused, tls_handshake
+	ResponseBody   string        `json:"response_body,omitempty"`
+	Timings        *PhaseTimings `json:"timings,omitempty"`
+	Steps          []StepResult  `json:"steps,omitempty"`
+	FailedStep     int           `json:"failed_step,omitempty"`     // 1-based
+	Attempts       int           `json:"attempts,omitempty"`        // includes failure confirmations
+	Skipped        bool          `json:"skipped,omitempty"`         // check not executed; see error_category
+	ConfigRevision string        `json:"config_revision,omitempty"` // monitor definition that produced the result
+	Maintenance    string        `json:"maintenance,omitempty"`     // ID of the maintenance window the check fell in
+	SuppressedBy   string        `json:"suppressed_by,omitempty"`   // UUID of the parent monitor that was down
+}
+
+// StepResult is the outcome of one api_flow step.
+type StepResult struct {
+	Name           string        `json:"name"`
+	Success        bool          `json:"success"`
+	StatusCode     int           `json:"status_code,omitempty"`
+	ResponseTimeMs int64         `json:"response_time_ms"`
+	ErrorMessage   string        `json:"error_message,omitempty"`
+	ErrorCategory  string        `json:"error_category,omitempty"`
+	Timings        *PhaseTimings `json:"timings,omitempty"`
+}
+
+// PhaseTimings breaks an HTTP check down by connection phase.
+// Phases are zero when skipped, e.g. DNS for IP literals or TLS for plain HTTP.
+type PhaseTimings struct {
+	DNSMs      int64 `json:"dns_ms"`
+	ConnectMs  int64 `json:"connect_ms"`
+	TLSMs      int64 `json:"tls_ms"`
+	TTFBMs     int64 `json:"ttfb_ms"`     // request written to first response byte
+	TransferMs int64 `json:"transfer_ms"` // first response byte to end of body
+	TotalMs    int64 `json:"total_ms"`
+	Reused     bool  `json:"connection_reused,omitempty"` // keep-alive connection, no DNS/connect/TLS
 }
 
 // SubmitResultsRequest is the batch result submission payload.
@@ -149,58 +355,202 @@ type SubmitResultsRequest struct {
 
 // SubmitResultsResponse is the response from the results endpoint.
 type SubmitResultsResponse struct {
-	Accepted int `json:"accepted"`
-	Rejected int `json:"rejected"`
+	Accepted   int         `json:"accepted"`
+	Rejected   int         `json:"rejected"`
+	Rejections []Rejection `json:"rejections,omitempty"`
 }
 
-// SubmitResults sends a batch of check results.
-func (c *Client) SubmitResults(pollerUUID string, results []CheckResult) (*SubmitResultsResponse, error) {
+// Rejection explains why the API refused one result of a batch.
+type Rejection struct {
+	Index       int    `json:"index"` // position in the submitted batch
+	MonitorUUID string `json:"monitor_uuid,omitempty"`
+	Reason      string `json:"reason"`
+	Retryable   bool   `json:"retryable"` // resubmitting later may succeed
+}
+
+// SubmitResults sends a batch of check results. Every attempt carries the
+// same Idempotency-Key so the API can discard duplicates; retries are still
+// limited to failures where the batch cannot have been stored.
+func (c *Client) SubmitResults(ctx context.Context, pollerUUID string, results []CheckResult) (*SubmitResultsResponse, error) {
 	req := SubmitResultsRequest{
 		PollerUUID: pollerUUID,
 		Results:    results,
 	}
 	resp := &SubmitResultsResponse{}
-	err := c.doJSON("POST", "/poller/results", req, resp)
+	err := c.doJSON(ctx, &apiRequest{
+		method:   "POST",
+		path:     "/poller/results",
+		body:     req,
+		header:   http.Header{"Idempotency-Key": {newIdempotencyKey()}},
+		compress: true,
+	}, resp)
 	if err != nil {
 		return nil, fmt.Errorf("submit results failed: %w", err)
 	}
 	return resp, nil
 }
 
-// doJSON performs an HTTP request with JSON body and decodes the JSON response.
-func (c *Client) doJSON(method, path string, body interface{}, result interface{}) error {
-	var bodyReader io.Reader
-	if body != nil {
-		data, err := json.Marshal(body)
+const (
+	// maxResponseBytes bounds a decompressed API response.
+	maxResponseBytes = 1 << 20
+	// maxMonitorsResponseBytes bounds the monitors response, which grows
+	// with the number of monitors assigned to the location.
+	maxMonitorsResponseBytes = 256 << 20
+)
+
+// errResponseTooLarge is returned for successful responses over the limit.
+var errResponseTooLarge = errors.New("response too large")
+
+// apiRequest describes one logical API call, which may take several attempts.
+type apiRequest struct {
+	method      string
+	path        string
+	query       url.Values
+	body        interface{}
+	header      http.Header
+	idempotent  bool         // safe to repeat even if the server may have processed it
+	compress    bool         // gzip the body if the API accepts it
+	response    *apiResponse // if set, filled in from the final response
+	maxResponse int64        // decompressed response limit; maxResponseBytes if zero
+}
+
+// apiResponse holds response metadata for callers that need more than the
+// decoded body.
+type apiResponse struct {
+	StatusCode int
+	Header     http.Header
+}
+
+// doJSON performs an API request with a JSON body and decodes the JSON
+// response, retrying according to the client's RetryPolicy.
+func (c *Client) doJSON(ctx context.Context, r *apiRequest, result interface{}) error {
+	var data []byte
+	if r.body != nil {
+		var err error
+		data, err = json.Marshal(r.body)
 		if err != nil {
 			return fmt.Errorf("failed to marshal request: %w", err)
 		}
+	}
+
+	var contentEncoding string
+	if r.compress && c.gzipRequests.Load() && len(data) >= minCompressSize {
+		compressed, err := gzipBytes(data)
+		if err != nil {
+			return fmt.Errorf("failed to compress request: %w", err)
+		}
+		data = compressed
+		contentEncoding = encodingGzip
+	}
+
+	attempts := c.retry.MaxAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	for attempt := 1; ; attempt++ {
+		err := c.attempt(ctx, r, data, contentEncoding, result)
+		if err == nil {
+			return nil
+		}
+		if attempt >= attempts || ctx.Err() != nil || !shouldRetry(err, r.idempotent) {
+			return err
+		}
+
+		delay := c.retry.Backoff(attempt)
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && statusErr.RetryAfter > 0 {
+			delay = statusErr.RetryAfter
+		}
+		log.Printf("[client] %s %s attempt %d/%d failed: %v; retrying in %s",
+			r.method, r.path, attempt, attempts, err, delay.Round(time.Millisecond))
+		c.observeRetry(r.path)
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// attempt makes a single HTTP request for r.
+func (c *Client) attempt(ctx context.Context, r *apiRequest, data []byte, contentEncoding string, result interface{}) error {
+	var bodyReader io.Reader
+	if data != nil {
 		bodyReader = bytes.NewReader(data)
 	}
 
-	req, err := http.NewRequest(method, c.baseURL+path, bodyReader)
+	target := c.baseURL + r.path
+	if len(r.query) > 0 {
+		target += "?" + r.query.Encode()
+	}
+	req, err := http.NewRequestWithContext(ctx, r.method, target, bodyReader)
 	if err != nil {
 		return fmt.Errorf("failed to create request: %w", err)
 	}
 
+	for key, values := range r.header {
+		req.Header[key] = values
+	}
 	req.Header.Set("X-Poller-Token", c.token)
 	req.Header.Set("Content-Type", "application/json")
 	req.Header.Set("User-Agent", "AlertPriority-Poller/1.0")
+	if contentEncoding != "" {
+		req.Header.Set("Content-Encoding", contentEncoding)
+	}
+	if c.compression {
+		req.Header.Set("Accept-Encoding", encodingGzip)
+	}
 
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		c.observe(r.path, "error")
 		return fmt.Errorf("request failed: %w", err)
 	}
 	defer resp.Body.Close()
 
-	respBody, err := io.ReadAll(io.LimitReader(resp.Body, 1024*1024)) // 1MB limit
+	switch {
+	case resp.StatusCode >= 500:
+		c.observe(r.path, "http_5xx")
+	case resp.StatusCode >= 400:
+		c.observe(r.path, "http_4xx")
+	default:
+		c.observe(r.path, "ok")
+	}
+	if r.response != nil {
+		r.response.StatusCode = resp.StatusCode
+		r.response.Header = resp.Header
+	}
+
+	respReader, err := responseReader(resp)
+	if err != nil {
+		return fmt.Errorf("failed to decompress response: %w", err)
+	}
+	limit := r.maxResponse
+	if limit <= 0 {
+		limit = maxResponseBytes
+	}
+	respBody, err := io.ReadAll(io.LimitReader(respReader, limit+1))
 	if err != nil {
 		return fmt.Errorf("failed to read response: %w", err)
 	}
+	if int64(len(respBody)) > limit {
+		if resp.StatusCode < 400 {
+			return fmt.Errorf("%w: over %d bytes", errResponseTooLarge, limit)
+		}
+		respBody = respBody[:limit]
+	}
 
 	if resp.StatusCode >= 400 {
-		log.Printf("[client] %s %s returned %d: %s", method, path, resp.StatusCode, string(respBody))
-		return fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(respBody))
+		log.Printf("[client] %s %s returned %d: %s", r.method, r.path, resp.StatusCode, string(respBody))
+		return &StatusError{
+			StatusCode: resp.StatusCode,
+			Body:       string(respBody),
+			RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+		}
 	}
 
 	if result != nil && len(respBody) > 0 {
@@ -211,3 +561,24 @@ func (c *Client) doJSON(method, path string, body interface{}, result interface{
 
 	return nil
 }
+
+func (c *Client) observe(endpoint, outcome string) {
+	if c.observer != nil {
+		c.observer.ObserveAPICall(endpoint, outcome)
+	}
+}
+
+func (c *Client) observeRetry(endpoint string) {
+	if c.observer != nil {
+		c.observer.ObserveAPIRetry(endpoint)
+	}
+}
+
+// newIdempotencyKey returns a random key identifying one logical submission.
+func newIdempotencyKey() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,56 @@
+package client
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// encodingGzip is the only content encoding the client negotiates.
+const encodingGzip = "gzip"
+
+// minCompressSize is the smallest request body worth compressing.
+const minCompressSize = 1024
+
+// gzipBytes compresses data.
+func gzipBytes(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// responseReader returns a reader over the decoded response body. The
+// transport's transparent decompression is disabled, so gzip is handled here.
+func responseReader(resp *http.Response) (io.Reader, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), encodingGzip) {
+		return resp.Body, nil
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if errors.Is(err, io.EOF) {
+		// Empty body, e.g. on 304 Not Modified
+		return bytes.NewReader(nil), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
+// hasEncoding reports whether encodings lists enc.
+func hasEncoding(encodings []string, enc string) bool {
+	for _, e := range encodings {
+		if strings.EqualFold(e, enc) {
+			return true
+		}
+	}
+	return false
+}
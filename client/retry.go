@@ -0,0 +1,97 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// maxRetryAfter caps how long a Retry-After header can make us wait.
+const maxRetryAfter = 5 * time.Minute
+
+// RetryPolicy controls how failed API requests are retried.
+type RetryPolicy struct {
+	MaxAttempts int           // total attempts including the first; 1 disables retries
+	BaseDelay   time.Duration // backoff before the first retry
+	MaxDelay    time.Duration // upper bound for exponential backoff
+}
+
+// StatusError is returned when the API answers with an error status.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	RetryAfter time.Duration // from the Retry-After header, if any
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.StatusCode, e.Body)
+}
+
+// Backoff returns the full-jitter delay before retry number n (1-based).
+func (p RetryPolicy) Backoff(n int) time.Duration {
+	ceiling := p.BaseDelay << (n - 1)
+	if ceiling <= 0 || ceiling > p.MaxDelay {
+		ceiling = p.MaxDelay
+	}
+	if ceiling <= 0 {
+		return 0
+	}
+	return rand.N(ceiling) + 1
+}
+
+// shouldRetry reports whether a failed attempt may be repeated. Requests that
+// are not idempotent are only retried when the server cannot have processed
+// them: the connection was never established, or the API explicitly asked us
+// to come back later (429, 503).
+func shouldRetry(err error, idempotent bool) bool {
+	if errors.Is(err, errResponseTooLarge) {
+		// The same response would arrive again
+		return false
+	}
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		switch statusErr.StatusCode {
+		case http.StatusTooManyRequests, http.StatusServiceUnavailable:
+			return true
+		case http.StatusRequestTimeout, http.StatusInternalServerError,
+			http.StatusBadGateway, http.StatusGatewayTimeout:
+			return idempotent
+		default:
+			return false
+		}
+	}
+
+	if idempotent {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" {
+		return true
+	}
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr)
+}
+
+// parseRetryAfter reads a Retry-After header given in seconds or as an HTTP date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	var d time.Duration
+	if secs, err := strconv.Atoi(v); err == nil {
+		d = time.Duration(secs) * time.Second
+	} else if t, err := http.ParseTime(v); err == nil {
+		d = time.Until(t)
+	}
+	if d < 0 {
+		return 0
+	}
+	if d > maxRetryAfter {
+		return maxRetryAfter
+	}
+	return d
+}
@@ -1,20 +1,45 @@
 package main
 
 import (
+	"appoller/cache"
 	"appoller/checker"
 	"appoller/client"
 	"appoller/config"
 	"appoller/health"
+	"appoller/maintenance"
+	"appoller/procstats"
 	"appoller/scheduler"
+	"appoller/spool"
+	"appoller/submitter"
+	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 	"time"
 )
 
+// shutdownGrace is how long in-flight checks may run after a shutdown signal
+// before they are cancelled.
+const shutdownGrace = 10 * time.Second
+
+// shutdownFlushTimeout bounds the final result flush and heartbeat, including
+// retries.
+const shutdownFlushTimeout = 15 * time.Second
+
+// Backoff between background registration attempts once the client's own
+// retries are exhausted.
+const (
+	registerRetryBase = 5 * time.Second
+	registerRetryMax  = 2 * time.Minute
+)
+
 // Set via -ldflags at build time
 var (
 	version   = "1.0.0"
@@ -38,69 +63,348 @@ func main() {
 	log.Printf("[main] API URL: %s", cfg.APIURL)
 	log.Printf("[main] poll_interval=%ds max_concurrency=%d batch_size=%d batch_interval=%ds",
 		cfg.PollInterval, cfg.MaxConcurrency, cfg.BatchSize, cfg.BatchInterval)
+	log.Printf("[main] check types: %s", strings.Join(checker.Types(), ", "))
+
+	// Start health server
+	healthServer := health.NewServer(cfg.HealthPort)
+	procCollector := procstats.NewCollector()
+	healthServer.SetProcessStats(procCollector.Last)
+	healthServer.Start()
 
 	// Initialize API client
 	apiClient := client.NewClient(cfg)
+	apiClient.SetObserver(healthServer)
 
-	// Register with API
-	log.Printf("[main] registering with API...")
-	regResp, err := apiClient.Register(hostname, version)
+	// Cancelled on SIGINT/SIGTERM; API calls made by the background loops
+	// use it so pending retries give up on shutdown.
+	runCtx, stopSignals := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stopSignals()
+
+	// Last registration and monitor list, for starting without the API
+	var monitorCache *cache.Store
+	if cfg.DataDir != "" {
+		monitorCache, err = cache.Open(cfg.DataDir)
+		if err != nil {
+			log.Printf("[main] monitor cache disabled: %v", err)
+			monitorCache = nil
+		}
+	}
+
+	// Maintenance windows from the config file and the local endpoint
+	var configuredWindows []maintenance.Window
+	if cfg.MaintenanceFile != "" {
+		configuredWindows, err = maintenance.LoadFile(cfg.MaintenanceFile)
+		if err != nil {
+			log.Fatalf("[main] configuration error: %v", err)
+		}
+	}
+	windows, err := maintenance.New(maintenance.Mode(cfg.MaintenanceMode), configuredWindows, cfg.DataDir)
 	if err != nil {
-		log.Fatalf("[main] failed to register: %v", err)
+		log.Fatalf("[main] configuration error: %v", err)
+	}
+	if n := len(windows.Windows(time.Now().UTC())); n > 0 {
+		log.Printf("[main] %d maintenance windows loaded", n)
+	}
+	if cfg.MaintenanceToken != "" {
+		h := windows.Handler(cfg.MaintenanceToken)
+		healthServer.Handle("/maintenance", h)
+		healthServer.Handle("/maintenance/", h)
 	}
-	log.Printf("[main] registered as poller %s at location %s (%s)",
-		regResp.PollerUUID, regResp.LocationName, regResp.LocationKey)
 
-	pollerUUID := regResp.PollerUUID
+	regReq := &client.RegisterRequest{
+		Hostname:     hostname,
+		Version:      version,
+		MonitorTypes: checker.Types(),
+	}
 
-	// Start health server
-	healthServer := health.NewServer(cfg.HealthPort)
-	healthServer.Start()
+	// registerUntilDone retries registration with backoff until it succeeds
+	// or ctx is cancelled, in which case it returns nil.
+	registerUntilDone := func(ctx context.Context) *client.RegisterResponse {
+		backoff := client.RetryPolicy{BaseDelay: registerRetryBase, MaxDelay: registerRetryMax}
+		for attempt := 1; ; attempt++ {
+			resp, err := apiClient.Register(ctx, regReq)
+			if err == nil {
+				return resp
+			}
+			if ctx.Err() != nil {
+				return nil
+			}
+			delay := backoff.Backoff(attempt)
+			log.Printf("[main] registration failed: %v; retrying in %s", err, delay.Round(time.Second))
+			select {
+			case <-ctx.Done():
+				return nil
+			case <-time.After(delay):
+			}
+		}
+	}
+
+	// pollerUUID holds the UUID assigned at registration, or the cached one
+	// while running offline.
+	var pollerUUID atomic.Value
+	var registered atomic.Bool
+	// acceptsSkipped is whether the API understands skipped results
+	var acceptsSkipped atomic.Bool
+	onRegistered := func(resp *client.RegisterResponse) {
+		log.Printf("[main] registered as poller %s at location %s (%s)",
+			resp.PollerUUID, resp.LocationName, resp.LocationKey)
+		pollerUUID.Store(resp.PollerUUID)
+		registered.Store(true)
+		acceptsSkipped.Store(resp.AcceptsSkipped())
+		if !resp.AcceptsSkipped() {
+			log.Printf("[main] API does not accept skipped results; skipped checks are reported in metrics only")
+		}
+		healthServer.SetOffline(false)
+		if monitorCache != nil {
+			if err := monitorCache.SetRegistration(resp); err != nil {
+				log.Printf("[main] %v", err)
+			}
+		}
+	}
 
 	// Initialize scheduler
-	sched := scheduler.NewScheduler()
+	sched := scheduler.NewScheduler(scheduler.Options{
+		InitialSpread: time.Duration(cfg.ScheduleSpread) * time.Second,
+		Jitter:        float64(cfg.ScheduleJitterPercent) / 100,
+	})
+
+	// Schedule and check history from the previous run, so a restart doesn't
+	// check every monitor again at once
+	var statePath string
+	if cfg.DataDir != "" {
+		statePath = filepath.Join(cfg.DataDir, "scheduler-state.json")
+		states, err := scheduler.LoadState(statePath)
+		if err != nil {
+			log.Printf("[main] %v; starting with a fresh schedule", err)
+		} else if len(states) > 0 {
+			sched.Restore(states)
+			log.Printf("[main] restored scheduler state for %d monitors", len(states))
+		}
+	}
+	saveState := func() {
+		if statePath == "" {
+			return
+		}
+		if err := sched.SaveState(statePath); err != nil {
+			log.Printf("[main] %v", err)
+		}
+	}
+
+	// Register with API. Without a cached monitor list there is nothing to
+	// check, so wait; with one, start checking offline and keep trying.
+	log.Printf("[main] registering with API...")
+	regResp, err := apiClient.Register(runCtx, regReq)
+	if err == nil {
+		onRegistered(regResp)
+	} else {
+		var cached cache.Snapshot
+		if monitorCache != nil {
+			cached = monitorCache.Snapshot()
+		}
+		if cached.Registration == nil {
+			log.Printf("[main] registration failed: %v; no cached monitors, waiting for the API", err)
+			regResp = registerUntilDone(runCtx)
+			if regResp == nil {
+				log.Printf("[main] shut down before registering")
+				return
+			}
+			onRegistered(regResp)
+		} else {
+			log.Printf("[main] registration failed: %v; starting offline with %d cached monitors from %s",
+				err, len(cached.Monitors), cached.SavedAt.Format(time.RFC3339))
+			pollerUUID.Store(cached.Registration.PollerUUID)
+			acceptsSkipped.Store(cached.Registration.AcceptsSkipped())
+			healthServer.SetOffline(true)
+			sched.UpdateMonitors(cached.Monitors)
+		}
+	}
+
+	// Durable spool for results the API could not take
+	var resultSpool *spool.Spool
+	if cfg.DataDir != "" {
+		resultSpool, err = spool.Open(spool.Options{
+			Dir:      filepath.Join(cfg.DataDir, "spool"),
+			MaxBytes: int64(cfg.SpoolMaxMB) * 1024 * 1024,
+			MaxAge:   time.Duration(cfg.SpoolMaxAgeHours) * time.Hour,
+			Fsync:    cfg.SpoolFsync,
+		})
+		if err != nil {
+			log.Printf("[main] result spool disabled: %v", err)
+			resultSpool = nil
+		} else {
+			healthServer.SetSpoolStats(resultSpool.Stats)
+			if st := resultSpool.Stats(); st.Results > 0 {
+				log.Printf("[main] %d undelivered results in spool", st.Results)
+			}
+		}
+	}
 
-	// Result buffer for batch submission
-	var resultMu sync.Mutex
-	resultBuffer := make([]client.CheckResult, 0, cfg.BatchSize)
+	// Result buffer and chunked batch submission
+	results := submitter.New(func(ctx context.Context, batch []client.CheckResult) (*client.SubmitResultsResponse, error) {
+		return apiClient.SubmitResults(ctx, pollerUUID.Load().(string), batch)
+	}, submitter.Options{
+		MaxResults:  cfg.BatchSize,
+		MaxBytes:    cfg.BatchMaxKB * 1024,
+		Parallelism: cfg.SubmitParallelism,
+		Spool:       resultSpool,
+		OnBufferSize: func(n int) {
+			healthServer.ResultBufferSize.Store(int64(n))
+		},
+	})
+	healthServer.SetRejections(results.Rejections)
 
-	// Signal handling
-	signals := make(chan os.Signal, 1)
-	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
 	done := make(chan struct{})
 
+	// Root context for check execution; cancelled to abort in-flight checks.
+	checkCtx, cancelChecks := context.WithCancel(context.Background())
+	defer cancelChecks()
+
 	// Worker pool for executing checks
-	checkChan := make(chan *client.MonitorAssignment, cfg.MaxConcurrency*2)
+	checkOpts := checker.Options{
+		TLSInsecure:  cfg.TLSInsecure,
+		ConfirmDelay: time.Duration(cfg.ConfirmDelayMs) * time.Millisecond,
+	}
+	// reportSkipped submits a result for a check that was not run. An API
+	// that doesn't know the skipped flag would count it as a failure, so
+	// then the check only shows up in metrics.
+	reportSkipped := func(r *checker.Result) {
+		if acceptsSkipped.Load() {
+			results.Add(r.ToClientResult(pollerUUID.Load().(string)))
+		}
+	}
+
+	checkChan := make(chan scheduler.Due, cfg.MaxConcurrency*2)
 	var wg sync.WaitGroup
+	healthServer.Workers.Store(int64(cfg.MaxConcurrency))
 
 	for i := 0; i < cfg.MaxConcurrency; i++ {
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			for m := range checkChan {
-				result := checker.Execute(m, cfg.TLSInsecure)
+			for d := range checkChan {
+				select {
+				case <-done:
+					// Shutting down: don't start checks still queued, and
+					// keep them due in the saved scheduler state.
+					sched.Requeue(d)
+					continue
+				default:
+				}
+
+				m := d.Monitor
+				start := time.Now()
+				lag := start.Sub(d.ScheduledAt)
+				healthServer.ObserveSchedulingLag(lag)
+				if lag >= d.Interval {
+					// The next run is already due: report this one as skipped
+					// rather than run a stale check, so the backlog drains.
+					skipped := checker.Skipped(m, checker.CategoryPollerOverloaded,
+						fmt.Sprintf("skipped: poller overloaded (started %s late)", lag.Round(time.Millisecond)))
+					healthServer.ObserveSkippedCheck(checker.CategoryPollerOverloaded)
+					reportSkipped(skipped)
+					continue
+				}
+
+				var inMaintenance string
+				if w, ok := windows.Active(m, start); ok {
+					healthServer.ObserveMaintenanceCheck(string(w.Mode))
+					if w.Mode == maintenance.ModeSkip {
+						skipped := checker.Skipped(m, checker.CategoryMaintenance, "skipped: maintenance window "+w.ID)
+						skipped.Maintenance = w.ID
+						reportSkipped(skipped)
+						continue
+					}
+					inMaintenance = w.ID
+				}
+
+				if parent, down := sched.DownParent(m); down {
+					// A parent is down: report this monitor as unreachable
+					// rather than as an independent failure.
+					suppressed := checker.ParentDown(m, parent)
+					suppressed.Maintenance = inMaintenance
+					healthServer.ObserveSuppressedCheck()
+					sched.RecordSuppressed(m.UUID, suppressed.CheckedAt, parent, suppressed.Hash())
+					reportSkipped(suppressed)
+					continue
+				}
+
+				healthServer.WorkersBusy.Add(1)
+				result := checker.Execute(checkCtx, m, checkOpts)
+				healthServer.WorkersBusy.Add(-1)
+				result.Maintenance = inMaintenance
+				if checkCtx.Err() != nil && !result.Success {
+					// Aborted by shutdown, not a real failure.
+					sched.Requeue(d)
+					continue
+				}
+				healthServer.ObserveCheck(m.MonitorType, result.Success, result.ErrorCategory, time.Since(start))
 				healthServer.ChecksExecuted.Add(1)
-				if !result.Success {
-					healthServer.Errors.Add(1)
+				healthServer.RecordHTTPTimings(result.Timings)
+				if parent, down := sched.DownParent(m); down && !result.Success {
+					// The parent went down while this check was running
+					result.ErrorMessage = "unreachable: parent down (" + parent + "): " + result.ErrorMessage
+					result.ErrorCategory = checker.CategoryParentDown
+					result.SuppressedBy = parent
+					healthServer.ObserveSuppressedCheck()
+					sched.RecordSuppressed(m.UUID, result.CheckedAt, parent, result.Hash())
+				} else {
+					if !result.Success {
+						healthServer.Errors.Add(1)
+					}
+					sched.RecordResult(m.UUID, result.CheckedAt, result.Success, result.Hash())
 				}
 
-				cr := result.ToClientResult(pollerUUID)
-				resultMu.Lock()
-				resultBuffer = append(resultBuffer, cr)
-				resultMu.Unlock()
+				results.Add(result.ToClientResult(pollerUUID.Load().(string)))
 			}
 		}()
 	}
 
-	// Monitor fetch loop
+	saveMonitors := func(monitors []client.MonitorAssignment) {
+		if monitorCache == nil {
+			return
+		}
+		if err := monitorCache.SetMonitors(monitors); err != nil {
+			log.Printf("[main] %v", err)
+		}
+	}
+
+	// syncMonitors fetches monitor changes since the last successful fetch
+	// and applies them to the scheduler. Only the fetch loop calls it.
+	var monitorSync client.MonitorSync
+	syncMonitors := func() error {
+		update, err := apiClient.FetchMonitors(runCtx, monitorSync)
+		if err != nil {
+			return err
+		}
+		monitorSync = update.Sync
+		switch {
+		case update.NotModified:
+			log.Printf("[main] monitors unchanged (%d)", sched.MonitorCount())
+		case update.Full:
+			sched.UpdateMonitors(update.Monitors)
+			saveMonitors(update.Monitors)
+			log.Printf("[main] loaded %d monitors", len(update.Monitors))
+		default:
+			sched.ApplyDelta(update.Changed, update.Removed)
+			saveMonitors(sched.Monitors())
+			log.Printf("[main] applied monitor delta: %d changed, %d removed, %d total",
+				len(update.Changed), len(update.Removed), sched.MonitorCount())
+		}
+		return nil
+	}
+
+	// Monitor fetch loop; a send on refreshMonitors triggers an early fetch
+	refreshMonitors := make(chan struct{}, 1)
 	go func() {
 		// Initial fetch immediately
-		monitors, err := apiClient.GetMonitors()
-		if err != nil {
+		if err := syncMonitors(); err != nil {
 			log.Printf("[main] initial monitor fetch failed: %v", err)
-		} else {
-			sched.UpdateMonitors(monitors)
-			log.Printf("[main] loaded %d monitors", len(monitors))
+			if monitorCache != nil && sched.MonitorCount() == 0 {
+				if cached := monitorCache.Snapshot().Monitors; len(cached) > 0 {
+					sched.UpdateMonitors(cached)
+					log.Printf("[main] using %d cached monitors", len(cached))
+				}
+			}
 		}
 
 		ticker := time.NewTicker(time.Duration(cfg.PollInterval) * time.Second)
@@ -109,40 +413,53 @@ func main() {
 			select {
 			case <-done:
 				return
+			case <-refreshMonitors:
 			case <-ticker.C:
-				monitors, err := apiClient.GetMonitors()
-				if err != nil {
-					log.Printf("[main] monitor fetch failed: %v", err)
-					continue
-				}
-				sched.UpdateMonitors(monitors)
-				log.Printf("[main] refreshed %d monitors", len(monitors))
+			}
+			if err := syncMonitors(); err != nil {
+				log.Printf("[main] monitor fetch failed: %v", err)
 			}
 		}
 	}()
 
-	// Check executor loop — polls scheduler every second for due checks
+	// Check dispatch — the scheduler wakes when the next check is due
+	schedDone := make(chan struct{})
 	go func() {
-		ticker := time.NewTicker(1 * time.Second)
-		defer ticker.Stop()
-		for {
+		defer close(schedDone)
+		sched.Run(done, func(d scheduler.Due) {
 			select {
-			case <-done:
-				return
-			case <-ticker.C:
-				dueChecks := sched.GetDueChecks(cfg.MaxConcurrency)
-				healthServer.QueueDepth.Store(int64(len(dueChecks)))
-				for _, m := range dueChecks {
-					select {
-					case checkChan <- m:
-					default:
-						log.Printf("[main] check channel full, dropping check for %s", m.UUID)
-					}
+			case checkChan <- d:
+			default:
+				// Every worker is busy and the queue is full. Wait for room;
+				// later checks stay queued in the scheduler meanwhile.
+				blockedAt := time.Now()
+				select {
+				case checkChan <- d:
+				case <-done:
+					sched.Requeue(d)
 				}
+				healthServer.ObserveDispatchBlocked(time.Since(blockedAt))
 			}
-		}
+			healthServer.QueueDepth.Store(int64(len(checkChan)))
+		})
 	}()
 
+	// Scheduler state checkpoints
+	if statePath != "" {
+		go func() {
+			ticker := time.NewTicker(time.Duration(cfg.StateCheckpointInterval) * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-done:
+					return
+				case <-ticker.C:
+					saveState()
+				}
+			}
+		}()
+	}
+
 	// Result submitter loop
 	go func() {
 		ticker := time.NewTicker(time.Duration(cfg.BatchInterval) * time.Second)
@@ -152,57 +469,67 @@ func main() {
 			case <-done:
 				return
 			case <-ticker.C:
-				resultMu.Lock()
-				if len(resultBuffer) == 0 {
-					resultMu.Unlock()
+				if !registered.Load() {
+					// Offline: results wait in the spool until registration succeeds
+					results.Spill()
 					continue
 				}
-				batch := make([]client.CheckResult, len(resultBuffer))
-				copy(batch, resultBuffer)
-				resultBuffer = resultBuffer[:0]
-				resultMu.Unlock()
-
-				resp, err := apiClient.SubmitResults(pollerUUID, batch)
-				if err != nil {
-					log.Printf("[main] failed to submit %d results: %v", len(batch), err)
-					// Re-add to buffer on failure
-					resultMu.Lock()
-					resultBuffer = append(batch, resultBuffer...)
-					resultMu.Unlock()
-				} else {
-					log.Printf("[main] submitted %d results (accepted: %d, rejected: %d)",
-						len(batch), resp.Accepted, resp.Rejected)
-				}
+				results.Flush(runCtx)
 			}
 		}
 	}()
 
+	// Process resource sampling
+	go procCollector.Run(done, 10*time.Second)
+
 	// Heartbeat loop
 	go func() {
 		ticker := time.NewTicker(30 * time.Second)
 		defer ticker.Stop()
+		var lastSkipped int64
+		var lastBlocked time.Duration
 		for {
 			select {
 			case <-done:
 				return
 			case <-ticker.C:
+				if !registered.Load() {
+					continue
+				}
 				status := "online"
-				resultMu.Lock()
-				queueSize := len(resultBuffer)
-				resultMu.Unlock()
-				if queueSize > cfg.BatchSize {
+				queueSize := results.Len()
+				skipped := healthServer.ChecksSkipped.Load()
+				blocked := healthServer.DispatchBlocked()
+				switch {
+				case skipped > lastSkipped || blocked > lastBlocked:
+					// Checks had to wait for workers or were skipped since
+					// the last heartbeat
+					status = "overloaded"
+				case queueSize > cfg.BatchSize:
 					status = "busy"
 				}
+				lastSkipped, lastBlocked = skipped, blocked
 
-				err := apiClient.Heartbeat(&client.HeartbeatRequest{
-					PollerUUID:         pollerUUID,
+				stats := healthServer.Stats()
+				proc := procCollector.Last()
+				err := apiClient.Heartbeat(runCtx, &client.HeartbeatRequest{
+					PollerUUID:         pollerUUID.Load().(string),
 					Status:             status,
+					CPUPercent:         proc.CPUPercent,
+					MemoryMB:           proc.MemoryMB(),
+					MemoryLimitMB:      proc.MemoryLimitMB(),
+					CgroupMemoryMB:     proc.CgroupUsageBytes / (1024 * 1024),
+					Goroutines:         proc.Goroutines,
 					ChecksExecuted:     healthServer.ChecksExecuted.Load(),
-					ChecksPerMinute:    float64(healthServer.ChecksPerMinute.Load()),
-					AvgCheckDurationMs: healthServer.AvgCheckDurationMs.Load(),
+					ChecksPerMinute:    stats.ChecksPerMinute,
+					AvgCheckDurationMs: stats.AvgDurationMs,
+					P95CheckDurationMs: stats.P95DurationMs,
+					ErrorRate:          stats.ErrorRate,
 					Errors:             healthServer.Errors.Load(),
 					UptimeSeconds:      healthServer.UptimeSeconds(),
 					QueueDepth:         queueSize,
+					ChecksSkipped:      skipped,
+					WorkerUtilization:  healthServer.WorkerUtilization(),
 					Version:            version,
 				})
 				if err != nil {
@@ -212,19 +539,36 @@ func main() {
 		}
 	}()
 
-	// Mark as ready
-	healthServer.SetReady(true)
-	log.Printf("[main] poller is ready, health endpoint on :%d", cfg.HealthPort)
+	// Mark as ready, or keep registering in the background while offline
+	if registered.Load() {
+		healthServer.SetReady(true)
+		log.Printf("[main] poller is ready, health endpoint on :%d", cfg.HealthPort)
+	} else {
+		log.Printf("[main] poller is running offline, health endpoint on :%d", cfg.HealthPort)
+		go func() {
+			if resp := registerUntilDone(runCtx); resp != nil {
+				onRegistered(resp)
+				select {
+				case refreshMonitors <- struct{}{}:
+				default:
+				}
+				healthServer.SetReady(true)
+				log.Printf("[main] poller is back online")
+			}
+		}()
+	}
 
 	// Wait for shutdown signal
-	<-signals
+	<-runCtx.Done()
+	stopSignals()
 	log.Printf("[main] received shutdown signal, draining...")
 
 	// Graceful shutdown
 	close(done)
+	<-schedDone
 	close(checkChan)
 
-	// Wait for in-flight checks (max 30s)
+	// Let in-flight checks finish, then cancel whatever is still running
 	shutdownDone := make(chan struct{})
 	go func() {
 		wg.Wait()
@@ -234,28 +578,36 @@ func main() {
 	select {
 	case <-shutdownDone:
 		log.Printf("[main] all checks completed")
-	case <-time.After(30 * time.Second):
-		log.Printf("[main] shutdown timeout, some checks may not have completed")
-	}
-
-	// Flush remaining results
-	resultMu.Lock()
-	if len(resultBuffer) > 0 {
-		log.Printf("[main] flushing %d remaining results", len(resultBuffer))
-		_, err := apiClient.SubmitResults(pollerUUID, resultBuffer)
-		if err != nil {
-			log.Printf("[main] failed to flush results: %v", err)
+	case <-time.After(shutdownGrace):
+		log.Printf("[main] cancelling checks still running after %s", shutdownGrace)
+		cancelChecks()
+		select {
+		case <-shutdownDone:
+		case <-time.After(5 * time.Second):
+			log.Printf("[main] shutdown timeout, some checks may not have completed")
 		}
 	}
-	resultMu.Unlock()
+
+	saveState()
+
+	// Final API calls get a bounded budget of their own
+	flushCtx, cancelFlush := context.WithTimeout(context.Background(), shutdownFlushTimeout)
+	defer cancelFlush()
+
+	// Flush remaining results, spooling what can't be delivered
+	results.Shutdown(flushCtx, registered.Load())
+
+	checker.CloseIdleConnections()
 
 	// Send final shutting_down heartbeat
-	_ = apiClient.Heartbeat(&client.HeartbeatRequest{
-		PollerUUID:    pollerUUID,
-		Status:        "shutting_down",
-		UptimeSeconds: healthServer.UptimeSeconds(),
-		Version:       version,
-	})
+	if registered.Load() {
+		_ = apiClient.Heartbeat(flushCtx, &client.HeartbeatRequest{
+			PollerUUID:    pollerUUID.Load().(string),
+			Status:        "shutting_down",
+			UptimeSeconds: healthServer.UptimeSeconds(),
+			Version:       version,
+		})
+	}
 
 	log.Printf("[main] poller shut down gracefully")
 }
@@ -10,15 +10,38 @@ import (
 
 // Config holds all poller configuration.
 type Config struct {
-	PollerToken    string `json:"poller_token"`    // AP_POLLER_TOKEN (required)
-	APIURL         string `json:"api_url"`         // AP_API_URL (default: https://api.alertpriority.com)
-	PollInterval   int    `json:"poll_interval"`   // AP_POLL_INTERVAL — seconds between monitor fetches (default: 60)
-	MaxConcurrency int    `json:"max_concurrency"` // AP_MAX_CONCURRENCY — max concurrent checks (default: 50)
-	BatchSize      int    `json:"batch_size"`      // AP_BATCH_SIZE — max results per batch POST (default: 100)
-	BatchInterval  int    `json:"batch_interval"`  // AP_BATCH_INTERVAL — seconds between batch submissions (default: 10)
-	HealthPort     int    `json:"health_port"`     // AP_HEALTH_PORT — local health endpoint port (default: 8089)
-	LogLevel       string `json:"log_level"`       // AP_LOG_LEVEL — "debug", "info", "warn", "error" (default: "info")
-	TLSInsecure    bool   `json:"tls_insecure"`    // AP_TLS_INSECURE — skip TLS verification for checks (default: false)
+	PollerToken    string `json:"poller_token"`     // AP_POLLER_TOKEN (required)
+	APIURL         string `json:"api_url"`          // AP_API_URL (default: https://api.alertpriority.com)
+	PollInterval   int    `json:"poll_interval"`    // AP_POLL_INTERVAL — seconds between monitor fetches (default: 60)
+	MaxConcurrency int    `json:"max_concurrency"`  // AP_MAX_CONCURRENCY — max concurrent checks (default: 50)
+	BatchSize      int    `json:"batch_size"`       // AP_BATCH_SIZE — max results per batch POST (default: 100)
+	BatchInterval  int    `json:"batch_interval"`   // AP_BATCH_INTERVAL — seconds between batch submissions (default: 10)
+	HealthPort     int    `json:"health_port"`      // AP_HEALTH_PORT — local health endpoint port (default: 8089)
+	LogLevel       string `json:"log_level"`        // AP_LOG_LEVEL — "debug", "info", "warn", "error" (default: "info")
+	TLSInsecure    bool   `json:"tls_insecure"`     // AP_TLS_INSECURE — skip TLS verification for checks (default: false)
+	ConfirmDelayMs int    `json:"confirm_delay_ms"` // AP_CONFIRM_DELAY_MS — delay between failure confirmation attempts (default: 1000)
+
+	APIMaxAttempts   int  `json:"api_max_attempts"`    // AP_API_MAX_ATTEMPTS — attempts per API request, including the first (default: 4)
+	APIRetryBaseMs   int  `json:"api_retry_base_ms"`   // AP_API_RETRY_BASE_MS — initial retry backoff (default: 500)
+	APIRetryMaxDelay int  `json:"api_retry_max_delay"` // AP_API_RETRY_MAX_DELAY — seconds, cap on retry backoff (default: 30)
+	APICompression   bool `json:"api_compression"`     // AP_API_COMPRESSION — gzip API payloads when the API supports it (default: true)
+
+	ScheduleSpread        int `json:"schedule_spread"`         // AP_SCHEDULE_SPREAD — seconds over which first checks of new monitors are spread; 0 runs them at once (default: 60)
+	ScheduleJitterPercent int `json:"schedule_jitter_percent"` // AP_SCHEDULE_JITTER_PERCENT — random shift of each run, as a percentage of its interval (default: 0, max: 50)
+
+	BatchMaxKB        int `json:"batch_max_kb"`       // AP_BATCH_MAX_KB — max encoded results per batch POST, before compression (default: 1024)
+	SubmitParallelism int `json:"submit_parallelism"` // AP_SUBMIT_PARALLELISM — batch POSTs in flight at once (default: 2)
+
+	DataDir          string `json:"data_dir"`            // AP_DATA_DIR — directory for durable local state; empty disables it (default: "")
+	SpoolMaxMB       int    `json:"spool_max_mb"`        // AP_SPOOL_MAX_MB — cap on spooled undelivered results (default: 256)
+	SpoolMaxAgeHours int    `json:"spool_max_age_hours"` // AP_SPOOL_MAX_AGE_HOURS — drop spooled results older than this (default: 72)
+	SpoolFsync       string `json:"spool_fsync"`         // AP_SPOOL_FSYNC — "always", "segment" or "never" (default: "always")
+
+	StateCheckpointInterval int `json:"state_checkpoint_interval"` // AP_STATE_CHECKPOINT_INTERVAL — seconds between scheduler state saves to AP_DATA_DIR (default: 30)
+
+	MaintenanceFile  string `json:"maintenance_file"`  // AP_MAINTENANCE_FILE — JSON file of maintenance windows (default: "")
+	MaintenanceToken string `json:"maintenance_token"` // AP_MAINTENANCE_TOKEN — bearer token for the local /maintenance endpoint; empty disables it (default: "")
+	MaintenanceMode  string `json:"maintenance_mode"`  // AP_MAINTENANCE_MODE — "skip" or "flag", for windows that don't set a mode (default: "flag")
 }
 
 // DefaultConfig returns a Config with default values.
@@ -32,6 +55,25 @@ func DefaultConfig() *Config {
 		HealthPort:     8089,
 		LogLevel:       "info",
 		TLSInsecure:    false,
+		ConfirmDelayMs: 1000,
+
+		APIMaxAttempts:   4,
+		APIRetryBaseMs:   500,
+		APIRetryMaxDelay: 30,
+		APICompression:   true,
+
+		ScheduleSpread: 60,
+
+		BatchMaxKB:        1024,
+		SubmitParallelism: 2,
+
+		SpoolMaxMB:       256,
+		SpoolMaxAgeHours: 72,
+		SpoolFsync:       "always",
+
+		StateCheckpointInterval: 30,
+
+		MaintenanceMode: "flag",
 	}
 }
 
@@ -87,6 +129,80 @@ func Load(configFile string) (*Config, error) {
 	if v := os.Getenv("AP_TLS_INSECURE"); v != "" {
 		cfg.TLSInsecure = v == "true" || v == "1"
 	}
+	if v := os.Getenv("AP_CONFIRM_DELAY_MS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ConfirmDelayMs = n
+		}
+	}
+
+	if v := os.Getenv("AP_API_MAX_ATTEMPTS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.APIMaxAttempts = n
+		}
+	}
+	if v := os.Getenv("AP_API_RETRY_BASE_MS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.APIRetryBaseMs = n
+		}
+	}
+	if v := os.Getenv("AP_API_RETRY_MAX_DELAY"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.APIRetryMaxDelay = n
+		}
+	}
+	if v := os.Getenv("AP_API_COMPRESSION"); v != "" {
+		cfg.APICompression = v == "true" || v == "1"
+	}
+	if v := os.Getenv("AP_SCHEDULE_SPREAD"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ScheduleSpread = n
+		}
+	}
+	if v := os.Getenv("AP_SCHEDULE_JITTER_PERCENT"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			cfg.ScheduleJitterPercent = n
+		}
+	}
+	if v := os.Getenv("AP_BATCH_MAX_KB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.BatchMaxKB = n
+		}
+	}
+	if v := os.Getenv("AP_SUBMIT_PARALLELISM"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.SubmitParallelism = n
+		}
+	}
+	if v := os.Getenv("AP_DATA_DIR"); v != "" {
+		cfg.DataDir = v
+	}
+	if v := os.Getenv("AP_SPOOL_MAX_MB"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.SpoolMaxMB = n
+		}
+	}
+	if v := os.Getenv("AP_SPOOL_MAX_AGE_HOURS"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.SpoolMaxAgeHours = n
+		}
+	}
+	if v := os.Getenv("AP_SPOOL_FSYNC"); v != "" {
+		cfg.SpoolFsync = strings.ToLower(v)
+	}
+	if v := os.Getenv("AP_STATE_CHECKPOINT_INTERVAL"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			cfg.StateCheckpointInterval = n
+		}
+	}
+	if v := os.Getenv("AP_MAINTENANCE_FILE"); v != "" {
+		cfg.MaintenanceFile = v
+	}
+	if v := os.Getenv("AP_MAINTENANCE_TOKEN"); v != "" {
+		cfg.MaintenanceToken = v
+	}
+	if v := os.Getenv("AP_MAINTENANCE_MODE"); v != "" {
+		cfg.MaintenanceMode = strings.ToLower(v)
+	}
 
 	// Validate required fields
 	if cfg.PollerToken == "" {
@@ -94,5 +210,19 @@ func Load(configFile string) (*Config, error) {
 	}
 	cfg.APIURL = strings.TrimRight(cfg.APIURL, "/")
 
+	switch cfg.SpoolFsync {
+	case "always", "segment", "never":
+	default:
+		return nil, fmt.Errorf("invalid spool_fsync %q: must be always, segment or never", cfg.SpoolFsync)
+	}
+	switch cfg.MaintenanceMode {
+	case "skip", "flag":
+	default:
+		return nil, fmt.Errorf("invalid maintenance_mode %q: must be skip or flag", cfg.MaintenanceMode)
+	}
+	if cfg.ScheduleJitterPercent < 0 || cfg.ScheduleJitterPercent > 50 {
+		return nil, fmt.Errorf("invalid schedule_jitter_percent %d: must be between 0 and 50", cfg.ScheduleJitterPercent)
+	}
+
 	return cfg, nil
 }
@@ -1,6 +1,10 @@
 package health
 
 import (
+	"appoller/client"
+	"appoller/procstats"
+	"appoller/spool"
+	"appoller/submitter"
 	"encoding/json"
 	"fmt"
 	"log"
@@ -9,25 +13,89 @@ import (
 	"time"
 )
 
-// Server provides health and readiness endpoints.
+// Server provides health, readiness and metrics endpoints. /metrics serves a
+// JSON summary and /metrics/prometheus the Prometheus text format;
+// /debug/rejections lists results the API refused.
 type Server struct {
 	port      int
+	mux       *http.ServeMux
 	startedAt time.Time
 	ready     atomic.Bool
+	offline   atomic.Bool
 
 	// Metrics exposed via /metrics
-	ChecksExecuted     atomic.Int64
-	ChecksPerMinute    atomic.Int64
-	Errors             atomic.Int64
-	QueueDepth         atomic.Int64
-	AvgCheckDurationMs atomic.Int64
+	ChecksExecuted      atomic.Int64
+	Errors              atomic.Int64
+	QueueDepth          atomic.Int64
+	ResultBufferSize    atomic.Int64
+	APIRetries          atomic.Int64
+	ChecksSkipped       atomic.Int64
+	ChecksInMaintenance atomic.Int64
+	ChecksSuppressed    atomic.Int64 // reported as parent_down
+	Workers             atomic.Int64 // worker pool size
+	WorkersBusy         atomic.Int64
+
+	stats      *rollingStats
+	process    func() procstats.Snapshot
+	spool      atomic.Pointer[func() spool.Stats]
+	rejections atomic.Pointer[func() submitter.RejectionReport]
+	httpPhases phaseTotals
+	schedLag   *histogram
+	blockedNs  atomic.Int64 // time dispatch waited for a free worker
+	prom       promMetrics
+}
+
+// phaseTotals accumulates HTTP phase timings so /metrics can report averages.
+type phaseTotals struct {
+	count    atomic.Int64
+	dns      atomic.Int64
+	connect  atomic.Int64
+	tls      atomic.Int64
+	ttfb     atomic.Int64
+	transfer atomic.Int64
+	total    atomic.Int64
+}
+
+// RecordHTTPTimings adds an HTTP check's phase breakdown to the running totals.
+func (s *Server) RecordHTTPTimings(t *client.PhaseTimings) {
+	if t == nil {
+		return
+	}
+	s.httpPhases.count.Add(1)
+	s.httpPhases.dns.Add(t.DNSMs)
+	s.httpPhases.connect.Add(t.ConnectMs)
+	s.httpPhases.tls.Add(t.TLSMs)
+	s.httpPhases.ttfb.Add(t.TTFBMs)
+	s.httpPhases.transfer.Add(t.TransferMs)
+	s.httpPhases.total.Add(t.TotalMs)
+}
+
+// httpPhaseAverages returns the mean duration of each HTTP phase in milliseconds.
+func (s *Server) httpPhaseAverages() map[string]int64 {
+	p := &s.httpPhases
+	n := p.count.Load()
+	if n == 0 {
+		n = 1
+	}
+	return map[string]int64{
+		"dns":      p.dns.Load() / n,
+		"connect":  p.connect.Load() / n,
+		"tls":      p.tls.Load() / n,
+		"ttfb":     p.ttfb.Load() / n,
+		"transfer": p.transfer.Load() / n,
+		"total":    p.total.Load() / n,
+	}
 }
 
 // NewServer creates a new health server.
 func NewServer(port int) *Server {
+	now := time.Now().UTC()
 	s := &Server{
 		port:      port,
-		startedAt: time.Now().UTC(),
+		mux:       http.NewServeMux(),
+		startedAt: now,
+		stats:     newRollingStats(now),
+		schedLag:  newHistogram(schedulingLagBuckets),
 	}
 	return s
 }
@@ -37,16 +105,89 @@ func (s *Server) SetReady(ready bool) {
 	s.ready.Store(ready)
 }
 
+// SetOffline marks the poller as running from cached monitors while it is
+// not registered with the API.
+func (s *Server) SetOffline(offline bool) {
+	s.offline.Store(offline)
+}
+
+// SetProcessStats sets the source of process resource figures for /metrics.
+// It must be called before Start.
+func (s *Server) SetProcessStats(fn func() procstats.Snapshot) {
+	s.process = fn
+}
+
+// processStats returns the latest process snapshot, if a source is set.
+func (s *Server) processStats() (procstats.Snapshot, bool) {
+	if s.process == nil {
+		return procstats.Snapshot{}, false
+	}
+	return s.process(), true
+}
+
+// SetSpoolStats sets the source of result spool figures for /metrics.
+// It may be called after Start.
+func (s *Server) SetSpoolStats(fn func() spool.Stats) {
+	s.spool.Store(&fn)
+}
+
+// spoolStats returns the result spool's figures, if a spool is configured.
+func (s *Server) spoolStats() (spool.Stats, bool) {
+	fn := s.spool.Load()
+	if fn == nil {
+		return spool.Stats{}, false
+	}
+	return (*fn)(), true
+}
+
+// SetRejections sets the source of result rejection figures for /metrics
+// and /debug/rejections. It may be called after Start.
+func (s *Server) SetRejections(fn func() submitter.RejectionReport) {
+	s.rejections.Store(&fn)
+}
+
+// rejectionReport returns the rejection figures, if a source is set.
+func (s *Server) rejectionReport() (submitter.RejectionReport, bool) {
+	fn := s.rejections.Load()
+	if fn == nil {
+		return submitter.RejectionReport{}, false
+	}
+	return (*fn)(), true
+}
+
+// Stats returns throughput, duration and error-rate figures for checks
+// completed in the last minute.
+func (s *Server) Stats() WindowStats {
+	return s.stats.snapshot(time.Now())
+}
+
+// WorkerUtilization returns the fraction of workers running a check.
+func (s *Server) WorkerUtilization() float64 {
+	n := s.Workers.Load()
+	if n == 0 {
+		return 0
+	}
+	return float64(s.WorkersBusy.Load()) / float64(n)
+}
+
 // UptimeSeconds returns the poller uptime in seconds.
 func (s *Server) UptimeSeconds() int64 {
 	return int64(time.Since(s.startedAt).Seconds())
 }
 
+// Handle registers an additional handler on the health server. It may be
+// called after Start.
+func (s *Server) Handle(pattern string, h http.Handler) {
+	s.mux.Handle(pattern, h)
+}
+
 // Start starts the health HTTP server in a goroutine.
 func (s *Server) Start() {
-	mux := http.NewServeMux()
+	mux := s.mux
 	mux.HandleFunc("/ready", s.handleReady)
 	mux.HandleFunc("/metrics", s.handleMetrics)
+	mux.HandleFunc("/metrics/prometheus", s.handlePrometheus)
+	mux.HandleFunc("/debug/rejections", s.handleRejections)
 
 	addr := fmt.Sprintf(":%d", s.port)
 	log.Printf("[health] listening on %s", addr)
@@ -62,6 +203,9 @@ func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 	if s.ready.Load() {
 		w.WriteHeader(http.StatusOK)
 		fmt.Fprint(w, "ok")
+	} else if s.offline.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		fmt.Fprint(w, "offline")
 	} else {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		fmt.Fprint(w, "not ready")
@@ -69,14 +213,53 @@ func (s *Server) handleReady(w http.ResponseWriter, r *http.Request) {
 }
 
 func (s *Server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	st := s.Stats()
+	metrics := map[string]interface{}{
+		"uptime_seconds":        s.UptimeSeconds(),
+		"ready":                 s.ready.Load(),
+		"offline":               s.offline.Load(),
+		"checks_executed":       s.ChecksExecuted.Load(),
+		"checks_per_minute":     st.ChecksPerMinute,
+		"errors":                s.Errors.Load(),
+		"queue_depth":           s.QueueDepth.Load(),
+		"scheduling_lag_avg_ms": s.schedLag.meanMs(),
+		"checks_skipped":        s.ChecksSkipped.Load(),
+		"checks_in_maintenance": s.ChecksInMaintenance.Load(),
+		"checks_suppressed":     s.ChecksSuppressed.Load(),
+		"workers_busy":          s.WorkersBusy.Load(),
+		"worker_utilization":    s.WorkerUtilization(),
+		"dispatch_blocked_ms":   time.Duration(s.blockedNs.Load()).Milliseconds(),
+		"result_buffer_size":    s.ResultBufferSize.Load(),
+		"api_retries":           s.APIRetries.Load(),
+		"avg_check_duration_ms": st.AvgDurationMs,
+		"p50_check_duration_ms": st.P50DurationMs,
+		"p95_check_duration_ms": st.P95DurationMs,
+		"p99_check_duration_ms": st.P99DurationMs,
+		"error_rate":            st.ErrorRate,
+		"http_checks_timed":     s.httpPhases.count.Load(),
+		"http_phase_avg_ms":     s.httpPhaseAverages(),
+	}
+	if ps, ok := s.processStats(); ok {
+		metrics["process"] = ps
+	}
+	if ss, ok := s.spoolStats(); ok {
+		metrics["spool"] = ss
+	}
+	if rr, ok := s.rejectionReport(); ok {
+		metrics["results_rejected"] = rr.Permanent
+		metrics["results_rejected_retryable"] = rr.Retryable
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(metrics)
+}
+
+func (s *Server) handleRejections(w http.ResponseWriter, r *http.Request) {
+	rr, ok := s.rejectionReport()
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(map[string]interface{}{
-		"uptime_seconds":       s.UptimeSeconds(),
-		"ready":                s.ready.Load(),
-		"checks_executed":      s.ChecksExecuted.Load(),
-		"checks_per_minute":    s.ChecksPerMinute.Load(),
-		"errors":               s.Errors.Load(),
-		"queue_depth":          s.QueueDepth.Load(),
-		"avg_check_duration_ms": s.AvgCheckDurationMs.Load(),
-	})
+	json.NewEncoder(w).Encode(rr)
 }
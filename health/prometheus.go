@@ -0,0 +1,344 @@
+package health
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// checkDurationBuckets are the upper bounds, in seconds, of the check
+// duration histogram.
+var checkDurationBuckets = []float64{0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// schedulingLagBuckets are the upper bounds, in seconds, of the scheduling
+// lag histogram.
+var schedulingLagBuckets = []float64{0.001, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// histogram is a fixed-bucket Prometheus histogram.
+type histogram struct {
+	mu     sync.Mutex
+	bounds []float64
+	counts []uint64 // per bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+func newHistogram(bounds []float64) *histogram {
+	return &histogram{bounds: bounds, counts: make([]uint64, len(bounds))}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.sum += v
+	h.count++
+	if i := sort.SearchFloat64s(h.bounds, v); i < len(h.bounds) {
+		h.counts[i]++
+	}
+}
+
+// meanMs returns the mean observation in milliseconds, for values observed
+// in seconds.
+func (h *histogram) meanMs() float64 {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.count == 0 {
+		return 0
+	}
+	return h.sum / float64(h.count) * 1000
+}
+
+// labeledCounters is a set of counters keyed by a label tuple.
+type labeledCounters struct {
+	mu     sync.Mutex
+	values map[string]float64 // key is the rendered label set
+}
+
+func (c *labeledCounters) add(labels string, v float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.values == nil {
+		c.values = make(map[string]float64)
+	}
+	c.values[labels] += v
+}
+
+func (c *labeledCounters) snapshot() map[string]float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make(map[string]float64, len(c.values))
+	for k, v := range c.values {
+		out[k] = v
+	}
+	return out
+}
+
+// promMetrics holds the metrics that exist only in the Prometheus view.
+type promMetrics struct {
+	checks      labeledCounters // type, result
+	checkErrors labeledCounters // category
+	apiRequests labeledCounters // endpoint, outcome
+	apiRetries  labeledCounters // endpoint
+	skipped     labeledCounters // reason
+	maintenance labeledCounters // mode
+
+	durationsMu sync.Mutex
+	durations   map[string]*histogram // keyed by monitor type
+}
+
+// ObserveCheck records one completed check in the rolling statistics and the
+// Prometheus metrics.
+func (s *Server) ObserveCheck(monitorType string, success bool, errorCategory string, duration time.Duration) {
+	s.stats.observe(time.Now(), duration, success)
+
+	result := "success"
+	if !success {
+		result = "failure"
+		if errorCategory == "" {
+			errorCategory = "unknown"
+		}
+		s.prom.checkErrors.add(promLabels("category", errorCategory), 1)
+	}
+	s.prom.checks.add(promLabels("type", monitorType, "result", result), 1)
+
+	s.prom.durationsMu.Lock()
+	if s.prom.durations == nil {
+		s.prom.durations = make(map[string]*histogram)
+	}
+	h, ok := s.prom.durations[monitorType]
+	if !ok {
+		h = newHistogram(checkDurationBuckets)
+		s.prom.durations[monitorType] = h
+	}
+	s.prom.durationsMu.Unlock()
+
+	h.observe(duration.Seconds())
+}
+
+// ObserveSchedulingLag records how late a check started relative to its
+// scheduled time.
+func (s *Server) ObserveSchedulingLag(lag time.Duration) {
+	if lag < 0 {
+		lag = 0
+	}
+	s.schedLag.observe(lag.Seconds())
+}
+
+// ObserveSkippedCheck records a check that was not executed.
+func (s *Server) ObserveSkippedCheck(reason string) {
+	s.ChecksSkipped.Add(1)
+	s.prom.skipped.add(promLabels("reason", reason), 1)
+}
+
+// ObserveMaintenanceCheck records a due check that fell in a maintenance
+// window, by the window's mode. Unlike ObserveSkippedCheck it does not count
+// towards overload.
+func (s *Server) ObserveMaintenanceCheck(mode string) {
+	s.ChecksInMaintenance.Add(1)
+	s.prom.maintenance.add(promLabels("mode", mode), 1)
+}
+
+// ObserveSuppressedCheck records a check reported as parent_down. Like
+// ObserveMaintenanceCheck it does not count towards overload.
+func (s *Server) ObserveSuppressedCheck() {
+	s.ChecksSuppressed.Add(1)
+}
+
+// ObserveDispatchBlocked records time the scheduler waited for a free worker.
+func (s *Server) ObserveDispatchBlocked(d time.Duration) {
+	s.blockedNs.Add(int64(d))
+}
+
+// DispatchBlocked returns the total time the scheduler has waited for a free
+// worker.
+func (s *Server) DispatchBlocked() time.Duration {
+	return time.Duration(s.blockedNs.Load())
+}
+
+// ObserveAPICall records the outcome of a request to the AlertPriority API.
+func (s *Server) ObserveAPICall(endpoint, outcome string) {
+	s.prom.apiRequests.add(promLabels("endpoint", endpoint, "outcome", outcome), 1)
+}
+
+// ObserveAPIRetry records a retried request to the AlertPriority API.
+func (s *Server) ObserveAPIRetry(endpoint string) {
+	s.APIRetries.Add(1)
+	s.prom.apiRetries.add(promLabels("endpoint", endpoint), 1)
+}
+
+func (s *Server) handlePrometheus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	bw := bufio.NewWriter(w)
+	defer bw.Flush()
+
+	ready := 0.0
+	if s.ready.Load() {
+		ready = 1
+	}
+	st := s.Stats()
+
+	writeProm(bw, "appoller_up", "gauge", "Whether the poller is registered and executing checks.", map[string]float64{"": ready})
+	offline := 0.0
+	if s.offline.Load() {
+		offline = 1
+	}
+	writeProm(bw, "appoller_offline", "gauge", "Whether the poller is checking cached monitors while unregistered.", map[string]float64{"": offline})
+	writeProm(bw, "appoller_uptime_seconds", "gauge", "Seconds since the poller started.", map[string]float64{"": float64(s.UptimeSeconds())})
+	writeProm(bw, "appoller_checks_executed_total", "counter", "Checks executed.", map[string]float64{"": float64(s.ChecksExecuted.Load())})
+	writeProm(bw, "appoller_check_errors_total", "counter", "Failed checks.", map[string]float64{"": float64(s.Errors.Load())})
+	writeProm(bw, "appoller_checks_total", "counter", "Checks executed by monitor type and result.", s.prom.checks.snapshot())
+	writeProm(bw, "appoller_check_failures_by_category_total", "counter", "Failed checks by error category.", s.prom.checkErrors.snapshot())
+	writeProm(bw, "appoller_checks_per_minute", "gauge", "Check throughput over the last minute.", map[string]float64{"": st.ChecksPerMinute})
+	writeProm(bw, "appoller_check_error_rate", "gauge", "Fraction of checks that failed over the last minute.", map[string]float64{"": st.ErrorRate})
+	writeProm(bw, "appoller_queue_depth", "gauge", "Checks waiting for a free worker.", map[string]float64{"": float64(s.QueueDepth.Load())})
+	writeProm(bw, "appoller_checks_skipped_total", "counter", "Due checks not executed, by reason.", s.prom.skipped.snapshot())
+	writeProm(bw, "appoller_maintenance_checks_total", "counter", "Due checks that fell in a maintenance window, by mode.", s.prom.maintenance.snapshot())
+	writeProm(bw, "appoller_checks_suppressed_total", "counter", "Checks reported as parent_down because a monitor they depend on was down.", map[string]float64{"": float64(s.ChecksSuppressed.Load())})
+	writeProm(bw, "appoller_workers", "gauge", "Size of the check worker pool.", map[string]float64{"": float64(s.Workers.Load())})
+	writeProm(bw, "appoller_workers_busy", "gauge", "Workers currently running a check.", map[string]float64{"": float64(s.WorkersBusy.Load())})
+	writeProm(bw, "appoller_dispatch_blocked_seconds_total", "counter", "Time due checks waited because every worker was busy and the queue was full.", map[string]float64{"": time.Duration(s.blockedNs.Load()).Seconds()})
+	writeProm(bw, "appoller_result_buffer_size", "gauge", "Results waiting to be submitted.", map[string]float64{"": float64(s.ResultBufferSize.Load())})
+	writeProm(bw, "appoller_api_requests_total", "counter", "Requests to the AlertPriority API by endpoint and outcome.", s.prom.apiRequests.snapshot())
+	writeProm(bw, "appoller_api_retries_total", "counter", "Retried requests to the AlertPriority API by endpoint.", s.prom.apiRetries.snapshot())
+
+	p := &s.httpPhases
+	writeProm(bw, "appoller_http_phase_seconds_total", "counter", "Cumulative time spent in each HTTP check phase.", map[string]float64{
+		promLabels("phase", "dns"):      float64(p.dns.Load()) / 1000,
+		promLabels("phase", "connect"):  float64(p.connect.Load()) / 1000,
+		promLabels("phase", "tls"):      float64(p.tls.Load()) / 1000,
+		promLabels("phase", "ttfb"):     float64(p.ttfb.Load()) / 1000,
+		promLabels("phase", "transfer"): float64(p.transfer.Load()) / 1000,
+		promLabels("phase", "total"):    float64(p.total.Load()) / 1000,
+	})
+	writeProm(bw, "appoller_http_phase_observations_total", "counter", "HTTP checks with phase timings.", map[string]float64{"": float64(p.count.Load())})
+
+	if ps, ok := s.processStats(); ok {
+		writeProm(bw, "appoller_process_cpu_percent", "gauge", "Process CPU usage; 100 is one full core.", map[string]float64{"": ps.CPUPercent})
+		writeProm(bw, "appoller_process_resident_memory_bytes", "gauge", "Resident set size.", map[string]float64{"": float64(ps.RSSBytes)})
+		writeProm(bw, "appoller_cgroup_memory_usage_bytes", "gauge", "Memory usage of the poller's cgroup.", map[string]float64{"": float64(ps.CgroupUsageBytes)})
+		writeProm(bw, "appoller_cgroup_memory_limit_bytes", "gauge", "Memory limit of the poller's cgroup; 0 when unlimited.", map[string]float64{"": float64(ps.CgroupLimitBytes)})
+		writeProm(bw, "appoller_go_heap_bytes", "gauge", "Go heap bytes in use.", map[string]float64{"": float64(ps.HeapBytes)})
+		writeProm(bw, "appoller_go_goroutines", "gauge", "Number of goroutines.", map[string]float64{"": float64(ps.Goroutines)})
+		writeProm(bw, "appoller_go_gc_pause_seconds_total", "counter", "Cumulative GC stop-the-world pause time.", map[string]float64{"": ps.GCPauseTotalMs / 1000})
+	}
+
+	if ss, ok := s.spoolStats(); ok {
+		writeProm(bw, "appoller_spool_segments", "gauge", "Segment files in the result spool.", map[string]float64{"": float64(ss.Segments)})
+		writeProm(bw, "appoller_spool_bytes", "gauge", "Bytes on disk in the result spool.", map[string]float64{"": float64(ss.Bytes)})
+		writeProm(bw, "appoller_spool_results", "gauge", "Undelivered results in the spool.", map[string]float64{"": float64(ss.Results)})
+		writeProm(bw, "appoller_spool_dropped_segments_total", "counter", "Spool segments dropped by size or age limits.", map[string]float64{"": float64(ss.DroppedSegments)})
+		writeProm(bw, "appoller_spool_dropped_results_total", "counter", "Results dropped by spool size or age limits.", map[string]float64{"": float64(ss.DroppedResults)})
+	}
+
+	if rr, ok := s.rejectionReport(); ok {
+		byReason := make(map[string]float64, len(rr.ByReason))
+		for reason, n := range rr.ByReason {
+			byReason[promLabels("reason", reason)] = float64(n)
+		}
+		writeProm(bw, "appoller_results_rejected_total", "counter", "Results permanently rejected by the API, by reason.", byReason)
+		writeProm(bw, "appoller_results_rejected_retryable_total", "counter", "Results rejected by the API as retryable and resubmitted.", map[string]float64{"": float64(rr.Retryable)})
+	}
+
+	s.writeDurationHistograms(bw)
+	writeHistogram(bw, "appoller_scheduling_lag_seconds", "Delay between a check's scheduled and actual start.", s.schedLag)
+}
+
+func (s *Server) writeDurationHistograms(bw *bufio.Writer) {
+	const name = "appoller_check_duration_seconds"
+
+	s.prom.durationsMu.Lock()
+	types := make([]string, 0, len(s.prom.durations))
+	for t := range s.prom.durations {
+		types = append(types, t)
+	}
+	hists := make(map[string]*histogram, len(types))
+	for _, t := range types {
+		hists[t] = s.prom.durations[t]
+	}
+	s.prom.durationsMu.Unlock()
+	sort.Strings(types)
+
+	fmt.Fprintf(bw, "# HELP %s Check execution time by monitor type.\n# TYPE %s histogram\n", name, name)
+	for _, t := range types {
+		h := hists[t]
+		h.mu.Lock()
+		var cumulative uint64
+		for i, bound := range h.bounds {
+			cumulative += h.counts[i]
+			fmt.Fprintf(bw, "%s_bucket{%s} %d\n", name, promLabels("type", t, "le", formatPromFloat(bound)), cumulative)
+		}
+		fmt.Fprintf(bw, "%s_bucket{%s} %d\n", name, promLabels("type", t, "le", "+Inf"), h.count)
+		fmt.Fprintf(bw, "%s_sum{%s} %s\n", name, promLabels("type", t), formatPromFloat(h.sum))
+		fmt.Fprintf(bw, "%s_count{%s} %d\n", name, promLabels("type", t), h.count)
+		h.mu.Unlock()
+	}
+}
+
+// writeHistogram writes an unlabeled histogram.
+func writeHistogram(bw *bufio.Writer, name, help string, h *histogram) {
+	fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s histogram\n", name, help, name)
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	var cumulative uint64
+	for i, bound := range h.bounds {
+		cumulative += h.counts[i]
+		fmt.Fprintf(bw, "%s_bucket{%s} %d\n", name, promLabels("le", formatPromFloat(bound)), cumulative)
+	}
+	fmt.Fprintf(bw, "%s_bucket{%s} %d\n", name, promLabels("le", "+Inf"), h.count)
+	fmt.Fprintf(bw, "%s_sum %s\n", name, formatPromFloat(h.sum))
+	fmt.Fprintf(bw, "%s_count %d\n", name, h.count)
+}
+
+// writeProm writes one metric family. Keys of values are rendered label sets;
+// the empty key is the unlabeled series.
+func writeProm(bw *bufio.Writer, name, typ, help string, values map[string]float64) {
+	fmt.Fprintf(bw, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, typ)
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if k == "" {
+			fmt.Fprintf(bw, "%s %s\n", name, formatPromFloat(values[k]))
+		} else {
+			fmt.Fprintf(bw, "%s{%s} %s\n", name, k, formatPromFloat(values[k]))
+		}
+	}
+}
+
+// promLabels renders name/value pairs as a Prometheus label set body.
+func promLabels(pairs ...string) string {
+	var b strings.Builder
+	for i := 0; i+1 < len(pairs); i += 2 {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(pairs[i])
+		b.WriteString(`="`)
+		b.WriteString(escapePromLabel(pairs[i+1]))
+		b.WriteByte('"')
+	}
+	return b.String()
+}
+
+func escapePromLabel(v string) string {
+	v = strings.ReplaceAll(v, `\`, `\\`)
+	v = strings.ReplaceAll(v, "\n", `\n`)
+	return strings.ReplaceAll(v, `"`, `\"`)
+}
+
+func formatPromFloat(v float64) string {
+	if math.IsInf(v, 1) {
+		return "+Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
@@ -0,0 +1,133 @@
+package health
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	// statsWindow is the span of the rolling check statistics.
+	statsWindow = time.Minute
+	// maxDurationSamples bounds memory used for percentile estimates.
+	maxDurationSamples = 4096
+)
+
+// WindowStats summarises checks completed within the rolling window.
+type WindowStats struct {
+	ChecksPerMinute float64 `json:"checks_per_minute"`
+	AvgDurationMs   int64   `json:"avg_check_duration_ms"`
+	P50DurationMs   int64   `json:"p50_check_duration_ms"`
+	P95DurationMs   int64   `json:"p95_check_duration_ms"`
+	P99DurationMs   int64   `json:"p99_check_duration_ms"`
+	ErrorRate       float64 `json:"error_rate"` // failed / total, 0..1
+}
+
+// rollingStats keeps per-second counters for the last statsWindow plus a
+// bounded ring of recent durations for percentiles.
+type rollingStats struct {
+	mu      sync.Mutex
+	started time.Time
+	buckets [int(statsWindow / time.Second)]statsBucket
+	samples [maxDurationSamples]durationSample
+	next    int // next sample slot
+}
+
+type statsBucket struct {
+	second int64
+	count  int64
+	errors int64
+	sumMs  int64
+}
+
+type durationSample struct {
+	at time.Time
+	ms int64
+}
+
+func newRollingStats(now time.Time) *rollingStats {
+	return &rollingStats{started: now}
+}
+
+func (r *rollingStats) observe(now time.Time, duration time.Duration, success bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	ms := duration.Milliseconds()
+	sec := now.Unix()
+	b := &r.buckets[sec%int64(len(r.buckets))]
+	if sec > b.second {
+		*b = statsBucket{second: sec}
+	}
+	// A slot holding a newer second means this sample is outside the window.
+	if sec == b.second {
+		b.count++
+		b.sumMs += ms
+		if !success {
+			b.errors++
+		}
+	}
+
+	r.samples[r.next] = durationSample{at: now, ms: ms}
+	r.next = (r.next + 1) % len(r.samples)
+}
+
+func (r *rollingStats) snapshot(now time.Time) WindowStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldest := now.Unix() - int64(len(r.buckets)) + 1
+	var count, errors, sumMs int64
+	for _, b := range r.buckets {
+		if b.second >= oldest && b.second <= now.Unix() {
+			count += b.count
+			errors += b.errors
+			sumMs += b.sumMs
+		}
+	}
+
+	var st WindowStats
+	if count == 0 {
+		return st
+	}
+
+	// Until a full window has elapsed, scale by the time actually observed.
+	span := now.Sub(r.started)
+	if span > statsWindow || span <= 0 {
+		span = statsWindow
+	}
+	if span < time.Second {
+		span = time.Second
+	}
+	st.ChecksPerMinute = float64(count) * float64(time.Minute) / float64(span)
+	st.AvgDurationMs = sumMs / count
+	st.ErrorRate = float64(errors) / float64(count)
+
+	cutoff := now.Add(-statsWindow)
+	durations := make([]int64, 0, len(r.samples))
+	for _, s := range r.samples {
+		if !s.at.IsZero() && s.at.After(cutoff) {
+			durations = append(durations, s.ms)
+		}
+	}
+	sort.Slice(durations, func(i, j int) bool { return durations[i] < durations[j] })
+	st.P50DurationMs = percentile(durations, 0.50)
+	st.P95DurationMs = percentile(durations, 0.95)
+	st.P99DurationMs = percentile(durations, 0.99)
+	return st
+}
+
+// percentile returns the nearest-rank percentile of sorted values.
+func percentile(sorted []int64, p float64) int64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	idx := int(float64(len(sorted))*p+0.5) - 1
+	if idx < 0 {
+		idx = 0
+	}
+	if idx >= len(sorted) {
+		idx = len(sorted) - 1
+	}
+	return sorted[idx]
+}
@@ -0,0 +1,135 @@
+package maintenance
+
+import (
+	"testing"
+	"time"
+)
+
+// bits returns the set with the given values.
+func bits(values ...int) uint64 {
+	var set uint64
+	for _, v := range values {
+		set |= 1 << v
+	}
+	return set
+}
+
+func TestParseCronField(t *testing.T) {
+	minute := cronFields[0]
+	for _, tc := range []struct {
+		in   string
+		want uint64
+	}{
+		{"*", 1<<60 - 1},
+		{"5", bits(5)},
+		{"1-3", bits(1, 2, 3)},
+		{"*/15", bits(0, 15, 30, 45)},
+		{"10/20", bits(10, 30, 50)},
+		{"1-10/3", bits(1, 4, 7, 10)},
+		{"1,3-4,59", bits(1, 3, 4, 59)},
+	} {
+		got, err := parseCronField(tc.in, minute)
+		if err != nil {
+			t.Errorf("%q: %v", tc.in, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("%q: got %b, want %b", tc.in, got, tc.want)
+		}
+	}
+
+	for _, in := range []string{"", "60", "-1", "5-1", "a", "1-b", "*/0", "*/x", "1,,2"} {
+		if _, err := parseCronField(in, minute); err == nil {
+			t.Errorf("%q: expected an error", in)
+		}
+	}
+	if _, err := parseCronField("0", cronFields[2]); err == nil {
+		t.Errorf("day of month 0: expected an error")
+	}
+}
+
+func TestCronMatches(t *testing.T) {
+	at := func(s string) time.Time {
+		t.Helper()
+		ts, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	for _, tc := range []struct {
+		expr string
+		at   string
+		want bool
+	}{
+		{"0 2 * * 6", "2026-10-17 02:00", true}, // Saturday
+		{"0 2 * * 6", "2026-10-17 02:01", false},
+		{"0 2 * * 6", "2026-10-18 02:00", false},
+		{"*/15 9-17 * * 1-5", "2026-10-16 09:45", true}, // Friday
+		{"*/15 9-17 * * 1-5", "2026-10-16 18:00", false},
+		{"*/15 9-17 * * 1-5", "2026-10-17 10:00", false},
+		{"0 0 * * 7", "2026-10-18 00:00", true}, // 7 is Sunday too
+		{"0 0 13 * *", "2026-11-13 00:00", true},
+		{"0 0 13 * *", "2026-11-14 00:00", false},
+		{"0 0 * 2 *", "2026-11-14 00:00", false},
+		// Both day fields restricted: either one matching qualifies
+		{"0 0 1 * 0", "2026-10-01 00:00", true}, // Thursday the 1st
+		{"0 0 1 * 0", "2026-10-18 00:00", true}, // Sunday the 18th
+		{"0 0 1 * 0", "2026-10-16 00:00", false},
+	} {
+		c, err := parseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("%q: %v", tc.expr, err)
+		}
+		if got := c.matches(at(tc.at)); got != tc.want {
+			t.Errorf("%q at %s: got %v, want %v", tc.expr, tc.at, got, tc.want)
+		}
+	}
+}
+
+func TestCronLastStartAcrossDST(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skipf("time zone data unavailable: %v", err)
+	}
+	utc := func(s string) time.Time {
+		t.Helper()
+		ts, err := time.Parse("2006-01-02 15:04", s)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ts
+	}
+	for _, tc := range []struct {
+		name   string
+		expr   string
+		within time.Duration
+		at     string // UTC
+		want   string // UTC start, or "" for none
+	}{
+		// Clocks jump from 02:00 EST to 03:00 EDT on 8 March 2026, so
+		// 02:30 local doesn't happen that day
+		{"skipped hour", "30 2 * * *", time.Hour, "2026-03-08 07:45", ""},
+		{"day after the jump", "30 2 * * *", time.Hour, "2026-03-09 07:00", "2026-03-09 06:30"},
+		{"before the jump", "30 1 * * *", time.Hour, "2026-03-08 06:45", "2026-03-08 06:30"},
+		// Clocks fall back from 02:00 EDT to 01:00 EST on 1 November 2026,
+		// so 01:30 local happens twice
+		{"first 01:30", "30 1 * * *", 30 * time.Minute, "2026-11-01 05:45", "2026-11-01 05:30"},
+		{"second 01:30", "30 1 * * *", 30 * time.Minute, "2026-11-01 06:45", "2026-11-01 06:30"},
+		{"between the two", "30 1 * * *", 30 * time.Minute, "2026-11-01 06:15", ""},
+	} {
+		c, err := parseCron(tc.expr)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.name, err)
+		}
+		got, ok := c.lastStart(utc(tc.at), tc.within, ny)
+		switch {
+		case tc.want == "" && ok:
+			t.Errorf("%s: got start %s, want none", tc.name, got.UTC())
+		case tc.want != "" && !ok:
+			t.Errorf("%s: no start, want %s", tc.name, tc.want)
+		case tc.want != "" && !got.Equal(utc(tc.want)):
+			t.Errorf("%s: got start %s, want %s", tc.name, got.UTC(), tc.want)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package maintenance
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// maxRequestBody caps the size of a window posted to the endpoint.
+const maxRequestBody = 64 * 1024
+
+// Handler serves the local maintenance endpoint, authenticated with
+// "Authorization: Bearer <token>":
+//
+//	GET    /maintenance       list windows that have not ended
+//	POST   /maintenance       add a window, returns it with its id
+//	DELETE /maintenance/{id}  remove a window added through the endpoint
+func (m *Manager) Handler(token string) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /maintenance", m.handleList)
+	mux.HandleFunc("POST /maintenance", m.handleAdd)
+	mux.HandleFunc("DELETE /maintenance/{id}", m.handleRemove)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		given, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeError(w, http.StatusUnauthorized, "invalid or missing token")
+			return
+		}
+		mux.ServeHTTP(w, r)
+	})
+}
+
+func (m *Manager) handleList(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]any{"windows": m.Windows(time.Now().UTC())})
+}
+
+func (m *Manager) handleAdd(w http.ResponseWriter, r *http.Request) {
+	var win Window
+	dec := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&win); err != nil {
+		writeError(w, http.StatusBadRequest, "invalid window: "+err.Error())
+		return
+	}
+
+	added, err := m.Add(win)
+	if err != nil && added.ID == "" {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	if err != nil {
+		// Stored, but won't survive a restart
+		log.Printf("[maintenance] %v", err)
+	}
+	log.Printf("[maintenance] added window %s (%s)", added.ID, added.Mode)
+	writeJSON(w, http.StatusCreated, Status{Window: added, Active: added.activeAt(time.Now().UTC())})
+}
+
+func (m *Manager) handleRemove(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	removed, err := m.Remove(id)
+	if err != nil {
+		log.Printf("[maintenance] %v", err)
+	}
+	if !removed {
+		writeError(w, http.StatusNotFound, "no such window")
+		return
+	}
+	log.Printf("[maintenance] removed window %s", id)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, map[string]string{"error": msg})
+}
@@ -0,0 +1,244 @@
+// Package maintenance suppresses or flags checks during planned maintenance
+// windows declared on the poller itself: recurring windows from a file and
+// one-off windows added through the local HTTP endpoint.
+package maintenance
+
+import (
+	"appoller/cache"
+	"appoller/client"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+)
+
+// fileName holds windows added through the API inside the data directory.
+const fileName = "maintenance.json"
+
+// Sources of a window.
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+)
+
+// windowFile is the layout of both the maintenance file and the persisted
+// API windows.
+type windowFile struct {
+	Windows []Window `json:"windows"`
+}
+
+// Status is a window along with whether it is in effect.
+type Status struct {
+	Window
+	Active bool `json:"active"`
+}
+
+// Manager decides which window, if any, applies to a monitor's check.
+type Manager struct {
+	mu          sync.Mutex
+	defaultMode Mode
+	windows     []*Window
+	path        string // where API windows are saved; empty keeps them in memory
+
+	// Recurring windows active during cachedMinute; they only change at
+	// minute boundaries, so they are worked out once per minute.
+	cachedMinute time.Time
+	cachedCron   []*Window
+}
+
+// LoadFile reads recurring and one-off windows from a JSON file of the form
+// {"windows": [...]}.
+func LoadFile(path string) ([]Window, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read maintenance file: %w", err)
+	}
+	var f windowFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to decode maintenance file: %w", err)
+	}
+	return f.Windows, nil
+}
+
+// New creates a manager with the configured windows. Windows added through
+// the API are persisted in dataDir when it is set, and reloaded here; a
+// damaged file of saved windows is logged and ignored.
+func New(defaultMode Mode, configured []Window, dataDir string) (*Manager, error) {
+	if defaultMode != ModeSkip && defaultMode != ModeFlag {
+		return nil, fmt.Errorf("invalid maintenance mode %q: must be skip or flag", defaultMode)
+	}
+	m := &Manager{defaultMode: defaultMode}
+	now := time.Now().UTC()
+
+	seen := make(map[string]bool)
+	for i := range configured {
+		w := configured[i]
+		if w.ID == "" {
+			w.ID = fmt.Sprintf("config-%d", i+1)
+		}
+		if seen[w.ID] {
+			return nil, fmt.Errorf("duplicate maintenance window id %q", w.ID)
+		}
+		seen[w.ID] = true
+		w.Source = SourceConfig
+		if err := w.prepare(defaultMode, now); err != nil {
+			return nil, fmt.Errorf("maintenance window %s: %w", w.ID, err)
+		}
+		m.windows = append(m.windows, &w)
+	}
+
+	if dataDir == "" {
+		return m, nil
+	}
+	m.path = filepath.Join(dataDir, fileName)
+	data, err := os.ReadFile(m.path)
+	if os.IsNotExist(err) {
+		return m, nil
+	}
+	if err != nil {
+		log.Printf("[maintenance] ignoring saved windows: %v", err)
+		return m, nil
+	}
+	var f windowFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		log.Printf("[maintenance] ignoring saved windows: %v", err)
+		return m, nil
+	}
+	for i := range f.Windows {
+		w := f.Windows[i]
+		if seen[w.ID] || w.prepare(defaultMode, now) != nil || w.expired(now) {
+			continue
+		}
+		seen[w.ID] = true
+		w.Source = SourceAPI
+		m.windows = append(m.windows, &w)
+	}
+	return m, nil
+}
+
+// Active returns the window in effect for the monitor at now. When several
+// apply, a skip window wins over a flag window.
+func (m *Manager) Active(mon *client.MonitorAssignment, now time.Time) (Window, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	minute := now.Truncate(time.Minute)
+	if !minute.Equal(m.cachedMinute) {
+		m.cachedMinute = minute
+		m.cachedCron = m.cachedCron[:0]
+		for _, w := range m.windows {
+			if w.cron != nil && w.activeAt(now) {
+				m.cachedCron = append(m.cachedCron, w)
+			}
+		}
+	}
+
+	var found *Window
+	consider := func(w *Window) {
+		if w.Scope.Matches(mon) && (found == nil || found.Mode == ModeFlag && w.Mode == ModeSkip) {
+			found = w
+		}
+	}
+	for _, w := range m.cachedCron {
+		consider(w)
+	}
+	for _, w := range m.windows {
+		if w.cron == nil && w.activeAt(now) {
+			consider(w)
+		}
+	}
+	if found == nil {
+		return Window{}, false
+	}
+	return *found, true
+}
+
+// Windows lists every window that has not ended.
+func (m *Manager) Windows(now time.Time) []Status {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	out := make([]Status, 0, len(m.windows))
+	for _, w := range m.windows {
+		if !w.expired(now) {
+			out = append(out, Status{Window: *w, Active: w.activeAt(now)})
+		}
+	}
+	return out
+}
+
+// Add validates and stores a window declared through the API.
+func (m *Manager) Add(w Window) (Window, error) {
+	now := time.Now().UTC()
+	w.ID = newID()
+	w.Source = SourceAPI
+	if err := w.prepare(m.defaultMode, now); err != nil {
+		return Window{}, err
+	}
+	if w.expired(now) {
+		return Window{}, fmt.Errorf("window has already ended")
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.windows = append(m.windows, &w)
+	m.cachedMinute = time.Time{}
+	return w, m.save(now)
+}
+
+// Remove deletes a window added through the API. It reports false if no
+// such window exists; configured windows can't be removed.
+func (m *Manager) Remove(id string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	i := slices.IndexFunc(m.windows, func(w *Window) bool {
+		return w.ID == id && w.Source == SourceAPI
+	})
+	if i < 0 {
+		return false, nil
+	}
+	m.windows = slices.Delete(m.windows, i, i+1)
+	m.cachedMinute = time.Time{}
+	return true, m.save(time.Now().UTC())
+}
+
+// save drops ended API windows and persists the rest. Callers hold m.mu.
+func (m *Manager) save(now time.Time) error {
+	m.windows = slices.DeleteFunc(m.windows, func(w *Window) bool {
+		return w.Source == SourceAPI && w.expired(now)
+	})
+	if m.path == "" {
+		return nil
+	}
+
+	f := windowFile{Windows: []Window{}}
+	for _, w := range m.windows {
+		if w.Source == SourceAPI {
+			f.Windows = append(f.Windows, *w)
+		}
+	}
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to encode maintenance windows: %w", err)
+	}
+	if err := cache.WriteFileAtomic(m.path, data); err != nil {
+		return fmt.Errorf("failed to write maintenance windows: %w", err)
+	}
+	return nil
+}
+
+// newID returns a random window ID.
+func newID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return fmt.Sprintf("%d", time.Now().UnixNano())
+	}
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,45 @@
+package maintenance
+
+import (
+	"appoller/client"
+	"testing"
+	"time"
+)
+
+func TestActiveSkipWinsOverFlag(t *testing.T) {
+	now := time.Date(2026, 10, 16, 12, 0, 0, 0, time.UTC)
+	start, end := now.Add(-time.Hour), now.Add(time.Hour)
+	later := now.Add(2 * time.Hour)
+
+	windows := []Window{
+		{ID: "flag-db", Mode: ModeFlag, Scope: Scope{Tags: []string{"db"}}, Start: &start, End: &end},
+		{ID: "skip-m1", Mode: ModeSkip, Scope: Scope{Monitors: []string{"m1"}}, Cron: "0 11 * * *", DurationMinutes: 120},
+		{ID: "flag-web", Mode: ModeFlag, Scope: Scope{Subdomains: []string{"web"}}, Cron: "0 11 * * *", DurationMinutes: 120},
+		{ID: "skip-web-later", Mode: ModeSkip, Scope: Scope{Subdomains: []string{"web"}}, Start: &end, End: &later},
+		{ID: "default-mode", Scope: Scope{Tags: []string{"cache"}}, Start: &start, End: &end},
+	}
+	m, err := New(ModeSkip, windows, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tc := range []struct {
+		name string
+		mon  client.MonitorAssignment
+		want string // window ID, or "" for none
+	}{
+		{"skip over earlier flag", client.MonitorAssignment{UUID: "m1", Tags: []string{"db"}}, "skip-m1"},
+		{"flag only", client.MonitorAssignment{UUID: "m2", Tags: []string{"db"}}, "flag-db"},
+		{"inactive skip ignored", client.MonitorAssignment{UUID: "m3", Subdomain: "web"}, "flag-web"},
+		{"default mode skip over flag", client.MonitorAssignment{UUID: "m4", Tags: []string{"db", "cache"}}, "default-mode"},
+		{"out of scope", client.MonitorAssignment{UUID: "m5", Tags: []string{"queue"}}, ""},
+	} {
+		w, ok := m.Active(&tc.mon, now)
+		switch {
+		case tc.want == "" && ok:
+			t.Errorf("%s: got window %s, want none", tc.name, w.ID)
+		case tc.want != "" && w.ID != tc.want:
+			t.Errorf("%s: got window %q, want %q", tc.name, w.ID, tc.want)
+		}
+	}
+}
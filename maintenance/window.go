@@ -0,0 +1,121 @@
+package maintenance
+
+import (
+	"appoller/client"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// maxDuration bounds recurring windows so finding the current occurrence
+// stays cheap.
+const maxDuration = 7 * 24 * time.Hour
+
+// Mode says what happens to checks during a window.
+type Mode string
+
+const (
+	ModeSkip Mode = "skip" // don't run the check; submit a skipped result
+	ModeFlag Mode = "flag" // run the check and flag its result
+)
+
+// Scope selects the monitors a window applies to. A monitor matches if any
+// of its UUID, subdomain or tags is listed. An empty scope matches every
+// monitor.
+type Scope struct {
+	Monitors   []string `json:"monitors,omitempty"`   // monitor UUIDs
+	Subdomains []string `json:"subdomains,omitempty"` // monitor subdomains
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// Matches reports whether the monitor falls within the scope.
+func (s Scope) Matches(m *client.MonitorAssignment) bool {
+	if len(s.Monitors) == 0 && len(s.Subdomains) == 0 && len(s.Tags) == 0 {
+		return true
+	}
+	if slices.Contains(s.Monitors, m.UUID) || slices.Contains(s.Subdomains, m.Subdomain) {
+		return true
+	}
+	for _, tag := range m.Tags {
+		if slices.Contains(s.Tags, tag) {
+			return true
+		}
+	}
+	return false
+}
+
+// Window is a maintenance window. Recurring windows set Cron and
+// DurationMinutes; one-off windows set End, and optionally Start.
+type Window struct {
+	ID     string `json:"id"`
+	Name   string `json:"name,omitempty"`
+	Mode   Mode   `json:"mode,omitempty"` // defaults to the manager's mode
+	Scope  Scope  `json:"scope"`
+	Source string `json:"source,omitempty"` // "config" or "api", set by the manager
+
+	Cron            string `json:"cron,omitempty"`             // five-field cron expression for window starts
+	DurationMinutes int    `json:"duration_minutes,omitempty"` // length of each recurrence
+	Timezone        string `json:"timezone,omitempty"`         // IANA name the cron expression is read in (default: UTC)
+
+	Start *time.Time `json:"start,omitempty"`
+	End   *time.Time `json:"end,omitempty"`
+
+	cron *cronSchedule
+	loc  *time.Location
+}
+
+// prepare validates the window and fills in defaults.
+func (w *Window) prepare(defaultMode Mode, now time.Time) error {
+	if w.Mode == "" {
+		w.Mode = defaultMode
+	}
+	if w.Mode != ModeSkip && w.Mode != ModeFlag {
+		return fmt.Errorf("invalid mode %q: must be skip or flag", w.Mode)
+	}
+
+	switch {
+	case w.Cron != "" && w.End != nil:
+		return fmt.Errorf("window sets both cron and end")
+	case w.Cron != "":
+		sched, err := parseCron(w.Cron)
+		if err != nil {
+			return err
+		}
+		d := time.Duration(w.DurationMinutes) * time.Minute
+		if d <= 0 || d > maxDuration {
+			return fmt.Errorf("invalid duration_minutes %d: must be between 1 and %d", w.DurationMinutes, int(maxDuration.Minutes()))
+		}
+		loc := time.UTC
+		if w.Timezone != "" {
+			if loc, err = time.LoadLocation(w.Timezone); err != nil {
+				return fmt.Errorf("invalid timezone %q: %w", w.Timezone, err)
+			}
+		}
+		w.cron, w.loc = sched, loc
+	case w.End != nil:
+		if w.Start == nil {
+			start := now.UTC()
+			w.Start = &start
+		}
+		if !w.End.After(*w.Start) {
+			return fmt.Errorf("window end must be after its start")
+		}
+	default:
+		return fmt.Errorf("window needs either cron and duration_minutes, or end")
+	}
+	return nil
+}
+
+// activeAt reports whether the window is in effect at t.
+func (w *Window) activeAt(t time.Time) bool {
+	if w.cron == nil {
+		return !t.Before(*w.Start) && t.Before(*w.End)
+	}
+	_, ok := w.cron.lastStart(t, time.Duration(w.DurationMinutes)*time.Minute, w.loc)
+	return ok
+}
+
+// expired reports whether a one-off window has ended.
+func (w *Window) expired(t time.Time) bool {
+	return w.End != nil && !t.Before(*w.End)
+}
@@ -0,0 +1,200 @@
+package procstats
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc/self/stat. It is 100
+// on every mainstream Linux architecture and cannot be queried without cgo.
+const clockTicks = 100
+
+// unlimitedThreshold treats cgroup v1 limits this large as "no limit".
+const unlimitedThreshold = 1 << 62
+
+// Snapshot is a point-in-time view of the poller process's resource usage.
+// Fields read from /proc or cgroup files are zero where unavailable, e.g. on
+// non-Linux hosts.
+type Snapshot struct {
+	SampledAt        time.Time `json:"sampled_at"`
+	CPUPercent       float64   `json:"cpu_percent"` // since the previous sample; 100 = one core
+	RSSBytes         int64     `json:"rss_bytes"`
+	CgroupUsageBytes int64     `json:"cgroup_memory_usage_bytes,omitempty"`
+	CgroupLimitBytes int64     `json:"cgroup_memory_limit_bytes,omitempty"` // 0 when unlimited
+	HeapBytes        int64     `json:"heap_bytes"`
+	Goroutines       int       `json:"goroutines"`
+	NumGC            uint32    `json:"num_gc"`
+	GCPauseTotalMs   float64   `json:"gc_pause_total_ms"`
+	LastGCPauseMs    float64   `json:"last_gc_pause_ms"`
+}
+
+// MemoryMB returns resident memory in megabytes.
+func (s Snapshot) MemoryMB() int64 {
+	return s.RSSBytes / (1024 * 1024)
+}
+
+// MemoryLimitMB returns the cgroup memory limit in megabytes, or 0 if none.
+func (s Snapshot) MemoryLimitMB() int64 {
+	return s.CgroupLimitBytes / (1024 * 1024)
+}
+
+// Collector samples process statistics. CPU usage is computed from the
+// difference between consecutive samples.
+type Collector struct {
+	mu       sync.Mutex
+	lastCPU  time.Duration
+	lastWall time.Time
+	last     Snapshot
+}
+
+// NewCollector creates a collector and takes an initial CPU reading.
+func NewCollector() *Collector {
+	c := &Collector{}
+	c.Sample()
+	return c
+}
+
+// Run samples every interval until done is closed.
+func (c *Collector) Run(done <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			c.Sample()
+		}
+	}
+}
+
+// Sample reads current statistics and returns them.
+func (c *Collector) Sample() Snapshot {
+	now := time.Now()
+	snap := Snapshot{
+		SampledAt:  now.UTC(),
+		Goroutines: runtime.NumGoroutine(),
+	}
+
+	var ms runtime.MemStats
+	runtime.ReadMemStats(&ms)
+	snap.HeapBytes = int64(ms.HeapAlloc)
+	snap.NumGC = ms.NumGC
+	snap.GCPauseTotalMs = float64(ms.PauseTotalNs) / 1e6
+	if ms.NumGC > 0 {
+		snap.LastGCPauseMs = float64(ms.PauseNs[(ms.NumGC+255)%256]) / 1e6
+	}
+
+	snap.RSSBytes = readRSS()
+	if snap.RSSBytes == 0 {
+		snap.RSSBytes = int64(ms.Sys)
+	}
+	snap.CgroupUsageBytes, snap.CgroupLimitBytes = readCgroupMemory()
+
+	cpu, cpuOK := readCPUTime()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if cpuOK {
+		if !c.lastWall.IsZero() {
+			if wall := now.Sub(c.lastWall); wall > 0 {
+				snap.CPUPercent = float64(cpu-c.lastCPU) / float64(wall) * 100
+			}
+		}
+		c.lastCPU = cpu
+		c.lastWall = now
+	}
+	c.last = snap
+	return snap
+}
+
+// Last returns the most recent sample without taking a new one.
+func (c *Collector) Last() Snapshot {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// readCPUTime returns user+system CPU time from /proc/self/stat.
+func readCPUTime() (time.Duration, bool) {
+	data, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return 0, false
+	}
+	// The command name may contain spaces; fields resume after the last ')'.
+	s := string(data)
+	idx := strings.LastIndexByte(s, ')')
+	if idx == -1 {
+		return 0, false
+	}
+	fields := strings.Fields(s[idx+1:])
+	// fields[0] is the state (field 3); utime and stime are fields 14 and 15.
+	if len(fields) < 13 {
+		return 0, false
+	}
+	utime, err1 := strconv.ParseInt(fields[11], 10, 64)
+	stime, err2 := strconv.ParseInt(fields[12], 10, 64)
+	if err1 != nil || err2 != nil {
+		return 0, false
+	}
+	return time.Duration(utime+stime) * time.Second / clockTicks, true
+}
+
+// readRSS returns VmRSS from /proc/self/status in bytes.
+func readRSS() int64 {
+	data, err := os.ReadFile("/proc/self/status")
+	if err != nil {
+		return 0
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		if !strings.HasPrefix(line, "VmRSS:") {
+			continue
+		}
+		fields := strings.Fields(line[len("VmRSS:"):])
+		if len(fields) == 0 {
+			return 0
+		}
+		kb, err := strconv.ParseInt(fields[0], 10, 64)
+		if err != nil {
+			return 0
+		}
+		return kb * 1024
+	}
+	return 0
+}
+
+// readCgroupMemory returns memory usage and limit for the process's cgroup,
+// trying cgroup v2 first and then v1. A limit of 0 means unlimited.
+func readCgroupMemory() (usage, limit int64) {
+	if u, ok := readIntFile("/sys/fs/cgroup/memory.current"); ok {
+		usage = u
+		if l, ok := readIntFile("/sys/fs/cgroup/memory.max"); ok {
+			limit = l // "max" fails to parse and leaves the limit at 0
+		}
+		return usage, limit
+	}
+
+	if u, ok := readIntFile("/sys/fs/cgroup/memory/memory.usage_in_bytes"); ok {
+		usage = u
+		if l, ok := readIntFile("/sys/fs/cgroup/memory/memory.limit_in_bytes"); ok && l < unlimitedThreshold {
+			limit = l
+		}
+	}
+	return usage, limit
+}
+
+func readIntFile(path string) (int64, bool) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, false
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	return n, true
+}
@@ -0,0 +1,58 @@
+package scheduler
+
+import (
+	"appoller/client"
+	"time"
+)
+
+// DownParent returns the first parent in m.DependsOn that is down: its last
+// check failed, or was itself suppressed because one of its own parents is
+// down. Parents this poller doesn't run are ignored, and a dependency cycle
+// never suppresses a monitor on its own account.
+func (s *Scheduler) DownParent(m *client.MonitorAssignment) (string, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	visiting := map[string]bool{m.UUID: true}
+	for _, parent := range m.DependsOn {
+		if s.isDown(parent, visiting) {
+			return parent, true
+		}
+	}
+	return "", false
+}
+
+// isDown reports whether a tracked monitor is down. Callers hold s.mu.
+func (s *Scheduler) isDown(uuid string, visiting map[string]bool) bool {
+	job, ok := s.monitors[uuid]
+	if !ok || visiting[uuid] {
+		return false
+	}
+	if job.SuppressedBy == "" {
+		return job.ConsecutiveFailures > 0
+	}
+
+	visiting[uuid] = true
+	defer delete(visiting, uuid)
+	for _, parent := range job.Monitor.DependsOn {
+		if s.isDown(parent, visiting) {
+			return true
+		}
+	}
+	return false
+}
+
+// RecordSuppressed records that a monitor's result was reported as
+// parent_down because parent was down. Its own failure count is left alone.
+func (s *Scheduler) RecordSuppressed(uuid string, checkedAt time.Time, parent, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.monitors[uuid]
+	if !ok {
+		return
+	}
+	job.LastRunAt = checkedAt
+	job.LastResultHash = hash
+	job.SuppressedBy = parent
+}
@@ -0,0 +1,106 @@
+package scheduler
+
+import (
+	"appoller/client"
+	"hash/fnv"
+	"math/rand/v2"
+	"time"
+)
+
+// defaultInterval applies to monitors without a check interval.
+const defaultInterval = 60 * time.Second
+
+// minInterval is the shortest check interval honoured.
+const minInterval = 100 * time.Millisecond
+
+// maxJitter caps Options.Jitter so runs never overlap their neighbours.
+const maxJitter = 0.5
+
+// Options tunes how checks are spread over time.
+type Options struct {
+	// InitialSpread is the window over which first checks of new monitors
+	// are spread. 0 runs them immediately.
+	InitialSpread time.Duration
+	// Jitter randomly shifts each run by up to this fraction of the
+	// interval, in either direction (0 to 0.5).
+	Jitter float64
+}
+
+// monitorHash returns a stable hash of a monitor UUID.
+func monitorHash(uuid string) uint64 {
+	h := fnv.New64a()
+	h.Write([]byte(uuid))
+	return h.Sum64()
+}
+
+// monitorInterval returns a monitor's check interval, preferring the
+// millisecond field when set.
+func monitorInterval(m *client.MonitorAssignment) time.Duration {
+	var interval time.Duration
+	switch {
+	case m.CheckIntervalMs > 0:
+		interval = time.Duration(m.CheckIntervalMs) * time.Millisecond
+	case m.CheckIntervalSeconds > 0:
+		interval = time.Duration(m.CheckIntervalSeconds) * time.Second
+	default:
+		return defaultInterval
+	}
+	return max(interval, minInterval)
+}
+
+// nextSlot returns the first time after t at which the monitor's phase
+// recurs. Phases are derived from the UUID, so a monitor keeps the same
+// offset within its interval across restarts and pollers, and monitors with
+// equal intervals are spread evenly rather than firing together.
+func nextSlot(t time.Time, uuid string, interval time.Duration) time.Time {
+	period := int64(interval)
+	phase := int64(monitorHash(uuid) % uint64(period))
+	ns := t.UnixNano()
+	slot := ns - mod(ns-phase, period) + period
+	return time.Unix(0, slot).UTC()
+}
+
+// firstCheck returns when a newly added monitor should first run: its phase
+// slot if that falls within the spread window, otherwise a hashed offset
+// inside the window. In the latter case the second run is the first phase
+// slot at least one interval later, so it comes one to two intervals after
+// the first.
+func (o Options) firstCheck(now time.Time, uuid string, interval time.Duration) time.Time {
+	if o.InitialSpread <= 0 {
+		return now
+	}
+	slot := nextSlot(now, uuid, interval)
+	if slot.Sub(now) <= o.InitialSpread {
+		return slot
+	}
+	return now.Add(time.Duration(monitorHash(uuid) % uint64(o.InitialSpread)))
+}
+
+// jitter returns a random shift for one run.
+func (o Options) jitter(interval time.Duration) time.Duration {
+	j := min(o.Jitter, maxJitter)
+	if j <= 0 {
+		return 0
+	}
+	span := int64(float64(interval) * j)
+	if span <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int64N(2*span+1) - span)
+}
+
+// later returns the later of a and b.
+func later(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
+
+func mod(a, b int64) int64 {
+	m := a % b
+	if m < 0 {
+		m += b
+	}
+	return m
+}
@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"appoller/client"
+	"testing"
+	"time"
+)
+
+// simulate runs a single monitor through popDue for the given span of
+// simulated time and returns the scheduled times of its runs.
+func simulate(t *testing.T, opts Options, m client.MonitorAssignment, span time.Duration) []time.Time {
+	t.Helper()
+	s := NewScheduler(opts)
+	s.UpdateMonitors([]client.MonitorAssignment{m})
+
+	var runs []time.Time
+	start := s.queue[0].NextCheckAt
+	for {
+		now := s.queue[0].NextCheckAt
+		if now.Sub(start) >= span {
+			return runs
+		}
+		d, _, ok := s.popDue(now)
+		if !ok {
+			t.Fatalf("check due at %s was not popped", now)
+		}
+		runs = append(runs, d.ScheduledAt)
+	}
+}
+
+func TestJitterKeepsRunsPerHour(t *testing.T) {
+	const hours = 100
+	for _, tc := range []struct {
+		interval time.Duration
+		jitter   float64
+	}{
+		{60 * time.Second, 0},
+		{60 * time.Second, 0.1},
+		{60 * time.Second, 0.5},
+		{10 * time.Second, 0.25},
+		{250 * time.Millisecond, 0.5},
+	} {
+		t.Run(tc.interval.String(), func(t *testing.T) {
+			m := client.MonitorAssignment{UUID: "3f2a9c1e-jitter", CheckIntervalMs: int(tc.interval.Milliseconds())}
+			runs := simulate(t, Options{Jitter: tc.jitter}, m, hours*time.Hour)
+
+			want := int(hours * time.Hour / tc.interval)
+			if diff := len(runs) - want; diff < -1 || diff > 1 {
+				t.Errorf("jitter %.2f: %d runs in %dh, want %d", tc.jitter, len(runs), hours, want)
+			}
+
+			// Neighbouring runs are at most 2*jitter*interval closer
+			// than one interval
+			minGap := tc.interval - time.Duration(2*tc.jitter*float64(tc.interval))
+			for i := 1; i < len(runs); i++ {
+				if gap := runs[i].Sub(runs[i-1]); gap < minGap {
+					t.Fatalf("jitter %.2f: runs %d and %d only %s apart, want at least %s",
+						tc.jitter, i-1, i, gap, minGap)
+				}
+			}
+		})
+	}
+}
+
+func TestSpreadFirstRunKeepsInterval(t *testing.T) {
+	for _, uuid := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		m := client.MonitorAssignment{UUID: uuid, CheckIntervalSeconds: 3600}
+		runs := simulate(t, Options{InitialSpread: time.Minute}, m, 5*time.Hour)
+		if len(runs) < 2 {
+			t.Fatalf("%s: %d runs", uuid, len(runs))
+		}
+		if gap := runs[1].Sub(runs[0]); gap < time.Hour || gap >= 2*time.Hour {
+			t.Errorf("%s: second run %s after the first, want one to two intervals", uuid, gap)
+		}
+		for i := 2; i < len(runs); i++ {
+			if gap := runs[i].Sub(runs[i-1]); gap != time.Hour {
+				t.Errorf("%s: runs %d and %d %s apart, want 1h", uuid, i-1, i, gap)
+			}
+		}
+	}
+}
@@ -2,82 +2,314 @@ package scheduler
 
 import (
 	"appoller/client"
+	"container/heap"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"log"
+	"sort"
 	"sync"
 	"time"
 )
 
 // CheckJob represents a scheduled check to execute.
 type CheckJob struct {
-	Monitor    *client.MonitorAssignment
+	Monitor     *client.MonitorAssignment
 	NextCheckAt time.Time
+
+	// Outcome of the most recent check, see RecordResult
+	LastRunAt           time.Time
+	ConsecutiveFailures int
+	LastResultHash      string
+	SuppressedBy        string // parent that was down, if the last result was suppressed
+
+	slot   time.Time // NextCheckAt before jitter; later runs are derived from it
+	spread bool      // slot is the first run, placed by the initial spread
+	index  int       // position in the scheduler's heap
+}
+
+// Due is a check handed out by Run. ScheduledAt is when it was meant to
+// start, so callers can measure scheduling lag.
+type Due struct {
+	Monitor     *client.MonitorAssignment
+	ScheduledAt time.Time
+	Interval    time.Duration
+
+	slot   time.Time // unjittered slot of ScheduledAt, for Requeue
+	spread bool      // job.spread at ScheduledAt, for Requeue
 }
 
 // Scheduler manages the internal check schedule.
-// It maintains a list of monitors and tracks when each should next be checked.
+// It keeps monitors in a min-heap ordered by next check time and wakes
+// exactly when the earliest one is due.
 type Scheduler struct {
 	mu       sync.RWMutex
 	monitors map[string]*CheckJob // keyed by monitor UUID
+	queue    jobHeap
+	opts     Options
+	restored map[string]MonitorState // saved state of monitors not yet seen, see Restore
+
+	wake chan struct{} // signalled when the earliest check time may have changed
 }
 
 // NewScheduler creates a new scheduler.
-func NewScheduler() *Scheduler {
+func NewScheduler(opts Options) *Scheduler {
 	return &Scheduler{
 		monitors: make(map[string]*CheckJob),
+		opts:     opts,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// add schedules the first check of a monitor the scheduler hasn't seen.
+// Callers hold s.mu.
+func (s *Scheduler) add(m *client.MonitorAssignment, now time.Time) {
+	stampRevision(m)
+	interval := monitorInterval(m)
+	job := &CheckJob{
+		Monitor:     m,
+		NextCheckAt: s.opts.firstCheck(now, m.UUID, interval),
+		spread:      true,
+	}
+	if st, ok := s.restored[m.UUID]; ok {
+		delete(s.restored, m.UUID)
+		job.LastRunAt = st.LastRunAt
+		job.ConsecutiveFailures = st.ConsecutiveFailures
+		job.LastResultHash = st.LastResultHash
+		job.SuppressedBy = st.SuppressedBy
+		// Keep the saved due time unless it has passed or no longer fits
+		// the interval; overdue monitors are spread like new ones.
+		if st.NextDueAt.After(now) && st.NextDueAt.Sub(now) <= interval {
+			job.NextCheckAt = st.NextDueAt
+			job.slot = st.NextSlot
+			job.spread = false
+		}
+	}
+	if job.slot.IsZero() {
+		job.slot = job.NextCheckAt
+	}
+	s.monitors[m.UUID] = job
+	heap.Push(&s.queue, job)
+}
+
+// update replaces a tracked monitor's definition. If its interval changed
+// the next check moves to the new interval's phase slot when that is sooner,
+// so a shortened interval takes effect immediately and a lengthened one after
+// the run already planned. Callers hold s.mu.
+func (s *Scheduler) update(job *CheckJob, m *client.MonitorAssignment, now time.Time) {
+	stampRevision(m)
+	old := job.Monitor
+	job.Monitor = m
+	if old.ConfigRevision == m.ConfigRevision {
+		return
+	}
+
+	oldInterval, newInterval := monitorInterval(old), monitorInterval(m)
+	if oldInterval == newInterval {
+		return
+	}
+	if slot := nextSlot(now, m.UUID, newInterval); slot.Before(job.NextCheckAt) {
+		job.NextCheckAt, job.slot, job.spread = slot, slot, false
+		heap.Fix(&s.queue, job.index)
+	}
+	log.Printf("[scheduler] monitor %s interval changed from %s to %s, next check at %s",
+		m.UUID, oldInterval, newInterval, job.NextCheckAt.Format(time.RFC3339Nano))
+}
+
+// remove drops a tracked monitor. Callers hold s.mu.
+func (s *Scheduler) remove(uuid string) {
+	job, ok := s.monitors[uuid]
+	if !ok {
+		return
+	}
+	delete(s.monitors, uuid)
+	heap.Remove(&s.queue, job.index)
+}
+
+// notify wakes Run to re-examine the head of the queue.
+func (s *Scheduler) notify() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
 	}
 }
 
 // UpdateMonitors replaces the full set of monitors from the API.
-// New monitors get an immediate first check; existing monitors keep their schedule.
+// New monitors are spread over the initial window; existing monitors keep
+// their schedule unless their interval changed.
 func (s *Scheduler) UpdateMonitors(monitors []client.MonitorAssignment) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.notify()
 
-	newSet := make(map[string]*CheckJob, len(monitors))
-
+	now := time.Now().UTC()
+	keep := make(map[string]bool, len(monitors))
 	for i := range monitors {
 		m := &monitors[i]
+		keep[m.UUID] = true
 		if existing, ok := s.monitors[m.UUID]; ok {
-			// Keep existing schedule
-			existing.Monitor = m
-			newSet[m.UUID] = existing
+			s.update(existing, m, now)
 		} else {
-			// New monitor: schedule first check immediately
-			newSet[m.UUID] = &CheckJob{
-				Monitor:     m,
-				NextCheckAt: time.Now().UTC(),
-			}
+			s.add(m, now)
 		}
 	}
 
-	s.monitors = newSet
+	for uuid := range s.monitors {
+		if !keep[uuid] {
+			s.remove(uuid)
+		}
+	}
+	// Saved state of monitors no longer assigned is of no further use
+	s.restored = nil
 }
 
-// GetDueChecks returns monitors that are due for checking and marks them as scheduled.
-func (s *Scheduler) GetDueChecks(maxBatch int) []*client.MonitorAssignment {
+// ApplyDelta applies incremental changes from the API. Changed and new
+// monitors are scheduled as in UpdateMonitors. Removed UUIDs that are not tracked are ignored.
+func (s *Scheduler) ApplyDelta(changed []client.MonitorAssignment, removed []string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
+	defer s.notify()
+
+	for _, uuid := range removed {
+		s.remove(uuid)
+	}
 
 	now := time.Now().UTC()
-	due := make([]*client.MonitorAssignment, 0)
+	for i := range changed {
+		m := &changed[i]
+		if existing, ok := s.monitors[m.UUID]; ok {
+			s.update(existing, m, now)
+			continue
+		}
+		s.add(m, now)
+	}
+}
+
+// Monitors returns a copy of every tracked monitor, ordered by UUID.
+func (s *Scheduler) Monitors() []client.MonitorAssignment {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
 
+	out := make([]client.MonitorAssignment, 0, len(s.monitors))
 	for _, job := range s.monitors {
-		if job.NextCheckAt.Before(now) || job.NextCheckAt.Equal(now) {
-			due = append(due, job.Monitor)
+		out = append(out, *job.Monitor)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].UUID < out[j].UUID })
+	return out
+}
+
+// Run hands each check to dispatch when it falls due, until done is closed.
+// It sleeps until the earliest check rather than polling. dispatch may block
+// to apply backpressure: checks falling due meanwhile stay queued and are
+// handed out oldest-first once it returns.
+func (s *Scheduler) Run(done <-chan struct{}, dispatch func(Due)) {
+	timer := time.NewTimer(time.Hour)
+	defer timer.Stop()
 
-			// Schedule next check
-			interval := time.Duration(job.Monitor.CheckIntervalSeconds) * time.Second
-			if interval == 0 {
-				interval = 60 * time.Second
+	for {
+		d, next, ok := s.popDue(time.Now().UTC())
+		if ok {
+			dispatch(d)
+			select {
+			case <-done:
+				return
+			default:
 			}
-			job.NextCheckAt = now.Add(interval)
+			continue
+		}
 
-			if len(due) >= maxBatch {
-				break
+		wait := time.Hour // idle: nothing scheduled, wait for notify
+		if !next.IsZero() {
+			wait = time.Until(next)
+		}
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
 			}
 		}
+		timer.Reset(wait)
+
+		select {
+		case <-done:
+			return
+		case <-s.wake:
+		case <-timer.C:
+		}
+	}
+}
+
+// popDue returns the earliest check if it is due at now and reschedules it.
+// Otherwise it reports when the next check is due (zero if nothing is
+// scheduled).
+func (s *Scheduler) popDue(now time.Time) (Due, time.Time, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.queue) == 0 {
+		return Due{}, time.Time{}, false
+	}
+	job := s.queue[0]
+	if job.NextCheckAt.After(now) {
+		return Due{}, job.NextCheckAt, false
+	}
+
+	interval := monitorInterval(job.Monitor)
+	d := Due{Monitor: job.Monitor, ScheduledAt: job.NextCheckAt, Interval: interval, slot: job.slot, spread: job.spread}
+
+	// Schedule the next check in the monitor's phase slot following this
+	// run's unjittered slot, so a run pulled early by jitter doesn't land in
+	// the same slot again. A run that started late skips the slots it missed.
+	slot := nextSlot(later(job.slot, now), job.Monitor.UUID, interval)
+	if job.spread && slot.Sub(job.slot) < interval {
+		// The first run was spread off its phase; keep a full interval
+		// before the next one
+		slot = slot.Add(interval)
+	}
+	job.slot, job.spread = slot, false
+	job.NextCheckAt = slot.Add(s.opts.jitter(interval))
+	if !job.NextCheckAt.After(now) {
+		job.NextCheckAt = slot
 	}
+	heap.Fix(&s.queue, 0)
+	return d, time.Time{}, true
+}
+
+// Requeue restores the schedule of a check handed out by Run that never ran,
+// for example because the poller shut down first, so the saved state
+// doesn't skip it.
+func (s *Scheduler) Requeue(d Due) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	defer s.notify()
+
+	job, ok := s.monitors[d.Monitor.UUID]
+	if !ok || job.Monitor != d.Monitor {
+		// Removed or redefined since
+		return
+	}
+	job.NextCheckAt, job.slot, job.spread = d.ScheduledAt, d.slot, d.spread
+	heap.Fix(&s.queue, job.index)
+}
 
-	return due
+// RecordResult records the outcome of a monitor's check. It is kept with the
+// schedule and persisted by SaveState.
+func (s *Scheduler) RecordResult(uuid string, checkedAt time.Time, success bool, hash string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	job, ok := s.monitors[uuid]
+	if !ok {
+		return
+	}
+	job.LastRunAt = checkedAt
+	job.LastResultHash = hash
+	job.SuppressedBy = ""
+	if success {
+		job.ConsecutiveFailures = 0
+	} else {
+		job.ConsecutiveFailures++
+	}
 }
 
 // MonitorCount returns the number of tracked monitors.
@@ -86,3 +318,17 @@ func (s *Scheduler) MonitorCount() int {
 	defer s.mu.RUnlock()
 	return len(s.monitors)
 }
+
+// stampRevision derives ConfigRevision from the monitor definition when the
+// API did not supply one.
+func stampRevision(m *client.MonitorAssignment) {
+	if m.ConfigRevision != "" {
+		return
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return
+	}
+	sum := sha256.Sum256(data)
+	m.ConfigRevision = hex.EncodeToString(sum[:8])
+}
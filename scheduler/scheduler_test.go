@@ -0,0 +1,36 @@
+package scheduler
+
+import (
+	"appoller/client"
+	"testing"
+	"time"
+)
+
+func TestLengthenedIntervalKeepsNextRun(t *testing.T) {
+	for _, uuid := range []string{"a", "b", "c", "d", "e", "f", "g", "h"} {
+		s := NewScheduler(Options{})
+		s.UpdateMonitors([]client.MonitorAssignment{{UUID: uuid, CheckIntervalSeconds: 30}})
+		for i := 0; i < 5; i++ {
+			if _, _, ok := s.popDue(s.queue[0].NextCheckAt); !ok {
+				t.Fatalf("%s: 30s check was not popped", uuid)
+			}
+		}
+
+		// Lengthen the interval just before the planned run
+		planned := s.queue[0].NextCheckAt
+		s.mu.Lock()
+		m := &client.MonitorAssignment{UUID: uuid, CheckIntervalSeconds: 3600}
+		s.update(s.monitors[uuid], m, planned.Add(-time.Second))
+		s.mu.Unlock()
+
+		d, _, ok := s.popDue(planned)
+		if !ok || !d.ScheduledAt.Equal(planned) {
+			t.Fatalf("%s: planned run at %s was not kept", uuid, planned)
+		}
+		want := nextSlot(planned, uuid, time.Hour)
+		if next := s.queue[0].NextCheckAt; !next.Equal(want) {
+			t.Errorf("%s: run after the interval change at %s, want first hourly slot %s",
+				uuid, next.Sub(planned), want.Sub(planned))
+		}
+	}
+}
@@ -0,0 +1,92 @@
+package scheduler
+
+import (
+	"appoller/cache"
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// MonitorState is what the scheduler keeps about a monitor across restarts.
+type MonitorState struct {
+	LastRunAt           time.Time `json:"last_run_at"`
+	NextDueAt           time.Time `json:"next_due_at"`
+	NextSlot            time.Time `json:"next_slot"` // NextDueAt before jitter
+	ConsecutiveFailures int       `json:"consecutive_failures,omitempty"`
+	LastResultHash      string    `json:"last_result_hash,omitempty"`
+	SuppressedBy        string    `json:"suppressed_by,omitempty"`
+}
+
+// stateFile is the on-disk layout written by SaveState.
+type stateFile struct {
+	SavedAt  time.Time               `json:"saved_at"`
+	Monitors map[string]MonitorState `json:"monitors"` // keyed by monitor UUID
+}
+
+// LoadState reads a state file written by SaveState. A missing file yields
+// no state.
+func LoadState(path string) (map[string]MonitorState, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read scheduler state: %w", err)
+	}
+	var f stateFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("failed to decode scheduler state: %w", err)
+	}
+	return f.Monitors, nil
+}
+
+// Restore seeds the scheduler with saved state. It applies to each monitor
+// when it is first added, so call it before loading monitors: a monitor
+// whose saved due time is still ahead keeps it instead of being checked
+// again right after a restart.
+func (s *Scheduler) Restore(states map[string]MonitorState) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.restored = make(map[string]MonitorState, len(states))
+	for uuid, st := range states {
+		if _, tracked := s.monitors[uuid]; !tracked {
+			s.restored[uuid] = st
+		}
+	}
+}
+
+// SaveState atomically writes the state of every tracked monitor to path.
+// Restored state of monitors not yet loaded is carried over, so saving
+// before the first monitor fetch loses nothing.
+func (s *Scheduler) SaveState(path string) error {
+	s.mu.RLock()
+	f := stateFile{
+		SavedAt:  time.Now().UTC(),
+		Monitors: make(map[string]MonitorState, len(s.monitors)+len(s.restored)),
+	}
+	for uuid, st := range s.restored {
+		f.Monitors[uuid] = st
+	}
+	for uuid, job := range s.monitors {
+		f.Monitors[uuid] = MonitorState{
+			LastRunAt:           job.LastRunAt,
+			NextDueAt:           job.NextCheckAt,
+			NextSlot:            job.slot,
+			ConsecutiveFailures: job.ConsecutiveFailures,
+			LastResultHash:      job.LastResultHash,
+			SuppressedBy:        job.SuppressedBy,
+		}
+	}
+	s.mu.RUnlock()
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return fmt.Errorf("failed to encode scheduler state: %w", err)
+	}
+	if err := cache.WriteFileAtomic(path, data); err != nil {
+		return fmt.Errorf("failed to write scheduler state: %w", err)
+	}
+	return nil
+}
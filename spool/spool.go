@@ -0,0 +1,377 @@
+package spool
+
+import (
+	"appoller/client"
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fsync policies.
+const (
+	FsyncAlways  = "always"  // fsync after every append
+	FsyncSegment = "segment" // fsync when a segment is sealed
+	FsyncNever   = "never"   // leave flushing to the OS
+)
+
+const (
+	segmentPrefix = "seg-"
+	segmentSuffix = ".jsonl"
+
+	defaultSegmentBytes = 1024 * 1024 // 1MB
+)
+
+// Options configures a Spool.
+type Options struct {
+	Dir          string
+	MaxBytes     int64         // total size cap; oldest segments are dropped beyond it
+	MaxAge       time.Duration // segments older than this are dropped; 0 disables
+	SegmentBytes int64         // rotate the active segment at this size (default 1MB)
+	Fsync        string        // FsyncAlways, FsyncSegment or FsyncNever
+}
+
+// Stats describes the spool's current contents and lifetime drops.
+type Stats struct {
+	Segments        int   `json:"segments"`
+	Bytes           int64 `json:"bytes"`
+	Results         int64 `json:"results"`
+	DroppedSegments int64 `json:"dropped_segments"`
+	DroppedResults  int64 `json:"dropped_results"`
+}
+
+// Segment is a sealed spool file read back for submission.
+type Segment struct {
+	name    string
+	Results []client.CheckResult
+}
+
+type segmentInfo struct {
+	name    string
+	created time.Time
+	size    int64
+	count   int64
+}
+
+// Spool is a disk-backed write-ahead queue of check results that could not be
+// delivered. Results are appended as JSON lines to an active segment file;
+// segments are sealed on rotation and drained oldest-first.
+type Spool struct {
+	mu   sync.Mutex
+	opts Options
+
+	sealed []*segmentInfo // oldest first
+	active *segmentInfo
+	file   *os.File
+
+	droppedSegments int64
+	droppedResults  int64
+}
+
+// Open opens or creates a spool in opts.Dir and indexes existing segments.
+func Open(opts Options) (*Spool, error) {
+	if opts.SegmentBytes <= 0 {
+		opts.SegmentBytes = defaultSegmentBytes
+	}
+	if opts.Fsync == "" {
+		opts.Fsync = FsyncAlways
+	}
+	if err := os.MkdirAll(opts.Dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create spool dir: %w", err)
+	}
+
+	entries, err := os.ReadDir(opts.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read spool dir: %w", err)
+	}
+
+	s := &Spool{opts: opts}
+	for _, e := range entries {
+		created, ok := parseSegmentName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		count, err := countLines(filepath.Join(opts.Dir, e.Name()))
+		if err != nil {
+			log.Printf("[spool] skipping unreadable segment %s: %v", e.Name(), err)
+			continue
+		}
+		// Segments left by a previous run are sealed; new appends start fresh.
+		s.sealed = append(s.sealed, &segmentInfo{
+			name:    e.Name(),
+			created: created,
+			size:    info.Size(),
+			count:   count,
+		})
+	}
+	sort.Slice(s.sealed, func(i, j int) bool { return s.sealed[i].name < s.sealed[j].name })
+
+	s.mu.Lock()
+	s.enforceLimits(time.Now())
+	s.mu.Unlock()
+	return s, nil
+}
+
+// Append writes results to the active segment.
+func (s *Spool) Append(results []client.CheckResult) error {
+	if len(results) == 0 {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for i := range results {
+		if err := enc.Encode(&results[i]); err != nil {
+			return fmt.Errorf("failed to encode result: %w", err)
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		if err := s.openActive(); err != nil {
+			return err
+		}
+	}
+
+	n, err := s.file.Write(buf.Bytes())
+	s.active.size += int64(n)
+	if err != nil {
+		return fmt.Errorf("failed to write spool segment: %w", err)
+	}
+	s.active.count += int64(len(results))
+
+	if s.opts.Fsync == FsyncAlways {
+		if err := s.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync spool segment: %w", err)
+		}
+	}
+
+	if s.active.size >= s.opts.SegmentBytes {
+		s.sealActive()
+	}
+
+	s.enforceLimits(time.Now())
+	return nil
+}
+
+// Next returns the oldest segment, sealing the active one if nothing else is
+// queued. It returns nil when the spool is empty. The segment stays on disk
+// until Commit is called.
+func (s *Spool) Next() (*Segment, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.enforceLimits(time.Now())
+	if len(s.sealed) == 0 && s.active != nil {
+		s.sealActive()
+	}
+
+	for len(s.sealed) > 0 {
+		info := s.sealed[0]
+		results, err := readSegment(filepath.Join(s.opts.Dir, info.name))
+		if err != nil {
+			log.Printf("[spool] dropping unreadable segment %s: %v", info.name, err)
+			s.dropOldest()
+			continue
+		}
+		return &Segment{name: info.name, Results: results}, nil
+	}
+	return nil, nil
+}
+
+// Commit removes a segment returned by Next after it has been delivered.
+func (s *Spool) Commit(seg *Segment) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, info := range s.sealed {
+		if info.name == seg.name {
+			s.sealed = append(s.sealed[:i], s.sealed[i+1:]...)
+			break
+		}
+	}
+	if err := os.Remove(filepath.Join(s.opts.Dir, seg.name)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove spool segment: %w", err)
+	}
+	return nil
+}
+
+// Stats returns the spool's current size and drop counters.
+func (s *Spool) Stats() Stats {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	st := Stats{
+		DroppedSegments: s.droppedSegments,
+		DroppedResults:  s.droppedResults,
+	}
+	for _, info := range s.segments() {
+		st.Segments++
+		st.Bytes += info.size
+		st.Results += info.count
+	}
+	return st
+}
+
+// Close seals the active segment.
+func (s *Spool) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sealActive()
+	return nil
+}
+
+// segments returns sealed and active segments, oldest first. Callers hold s.mu.
+func (s *Spool) segments() []*segmentInfo {
+	all := append([]*segmentInfo(nil), s.sealed...)
+	if s.active != nil {
+		all = append(all, s.active)
+	}
+	return all
+}
+
+// openActive starts a new segment file. Callers hold s.mu.
+func (s *Spool) openActive() error {
+	now := time.Now()
+	name := segmentName(now)
+	// Guard against two segments in the same nanosecond tick.
+	for s.hasSegment(name) {
+		now = now.Add(time.Nanosecond)
+		name = segmentName(now)
+	}
+
+	f, err := os.OpenFile(filepath.Join(s.opts.Dir, name), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to create spool segment: %w", err)
+	}
+	s.file = f
+	s.active = &segmentInfo{name: name, created: now}
+	return nil
+}
+
+// sealActive closes the active segment and queues it. Callers hold s.mu.
+func (s *Spool) sealActive() {
+	if s.file != nil {
+		if s.opts.Fsync != FsyncNever {
+			if err := s.file.Sync(); err != nil {
+				log.Printf("[spool] failed to sync segment %s: %v", s.active.name, err)
+			}
+		}
+		if err := s.file.Close(); err != nil {
+			log.Printf("[spool] failed to close segment %s: %v", s.active.name, err)
+		}
+		s.file = nil
+	}
+	if s.active != nil {
+		s.sealed = append(s.sealed, s.active)
+		s.active = nil
+	}
+}
+
+// enforceLimits drops the oldest segments until the spool is within its age
+// and size caps. Callers hold s.mu.
+func (s *Spool) enforceLimits(now time.Time) {
+	if s.opts.MaxAge > 0 {
+		for len(s.sealed) > 0 && now.Sub(s.sealed[0].created) > s.opts.MaxAge {
+			s.dropOldest()
+		}
+	}
+	if s.opts.MaxBytes > 0 {
+		for len(s.sealed) > 0 && s.totalBytes() > s.opts.MaxBytes {
+			s.dropOldest()
+		}
+	}
+}
+
+// dropOldest deletes the oldest sealed segment. Callers hold s.mu.
+func (s *Spool) dropOldest() {
+	info := s.sealed[0]
+	s.sealed = s.sealed[1:]
+	if err := os.Remove(filepath.Join(s.opts.Dir, info.name)); err != nil && !os.IsNotExist(err) {
+		log.Printf("[spool] failed to remove segment %s: %v", info.name, err)
+	}
+	s.droppedSegments++
+	s.droppedResults += info.count
+	log.Printf("[spool] dropped segment %s with %d results (spool limits exceeded)", info.name, info.count)
+}
+
+func (s *Spool) totalBytes() int64 {
+	var total int64
+	for _, info := range s.segments() {
+		total += info.size
+	}
+	return total
+}
+
+func (s *Spool) hasSegment(name string) bool {
+	for _, info := range s.segments() {
+		if info.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func segmentName(t time.Time) string {
+	return fmt.Sprintf("%s%020d%s", segmentPrefix, t.UnixNano(), segmentSuffix)
+}
+
+func parseSegmentName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, segmentPrefix) || !strings.HasSuffix(name, segmentSuffix) {
+		return time.Time{}, false
+	}
+	ns, err := strconv.ParseInt(strings.TrimSuffix(strings.TrimPrefix(name, segmentPrefix), segmentSuffix), 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(0, ns), true
+}
+
+// readSegment decodes a segment file. A torn final line from a crash
+// mid-append is skipped.
+func readSegment(path string) ([]client.CheckResult, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var results []client.CheckResult
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 4*1024*1024)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var r client.CheckResult
+		if err := json.Unmarshal(line, &r); err != nil {
+			log.Printf("[spool] skipping corrupt record in %s: %v", filepath.Base(path), err)
+			continue
+		}
+		results = append(results, r)
+	}
+	return results, scanner.Err()
+}
+
+func countLines(path string) (int64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return int64(bytes.Count(data, []byte{'\n'})), nil
+}
@@ -0,0 +1,109 @@
+package submitter
+
+import (
+	"appoller/client"
+	"sync"
+	"time"
+)
+
+// maxRecentRejections bounds the rejection history kept for debugging.
+const maxRecentRejections = 100
+
+// RejectedResult is one result the API refused.
+type RejectedResult struct {
+	At          time.Time `json:"at"`
+	MonitorUUID string    `json:"monitor_uuid"`
+	CheckedAt   string    `json:"checked_at"`
+	Reason      string    `json:"reason"`
+	Retryable   bool      `json:"retryable"`
+}
+
+// RejectionReport summarises per-result rejections since startup.
+type RejectionReport struct {
+	Permanent int64            `json:"permanent"`
+	Retryable int64            `json:"retryable"`
+	ByReason  map[string]int64 `json:"by_reason"` // permanent rejections
+	Recent    []RejectedResult `json:"recent"`    // newest first
+}
+
+// rejectionLog counts rejections and keeps the most recent ones.
+type rejectionLog struct {
+	mu        sync.Mutex
+	permanent int64
+	retryable int64
+	byReason  map[string]int64
+	recent    []RejectedResult // ring, oldest overwritten first
+	next      int
+}
+
+func (l *rejectionLog) record(r *client.CheckResult, rej client.Rejection) {
+	reason := rej.Reason
+	if reason == "" {
+		reason = "unspecified"
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if rej.Retryable {
+		l.retryable++
+	} else {
+		l.permanent++
+		if l.byReason == nil {
+			l.byReason = make(map[string]int64)
+		}
+		l.byReason[reason]++
+	}
+
+	entry := RejectedResult{
+		At:          time.Now().UTC(),
+		MonitorUUID: r.MonitorUUID,
+		CheckedAt:   r.CheckedAt,
+		Reason:      reason,
+		Retryable:   rej.Retryable,
+	}
+	if len(l.recent) < maxRecentRejections {
+		l.recent = append(l.recent, entry)
+	} else {
+		l.recent[l.next] = entry
+	}
+	l.next = (l.next + 1) % maxRecentRejections
+}
+
+func (l *rejectionLog) report() RejectionReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rep := RejectionReport{
+		Permanent: l.permanent,
+		Retryable: l.retryable,
+		ByReason:  make(map[string]int64, len(l.byReason)),
+		Recent:    make([]RejectedResult, 0, len(l.recent)),
+	}
+	for k, v := range l.byReason {
+		rep.ByReason[k] = v
+	}
+	// Walk the ring backwards from the newest entry
+	for i := 0; i < len(l.recent); i++ {
+		idx := (l.next - 1 - i + maxRecentRejections) % maxRecentRejections
+		rep.Recent = append(rep.Recent, l.recent[idx])
+	}
+	return rep
+}
+
+// rejectedIndex resolves a rejection to a position in chunk, by index when it
+// is consistent and otherwise by monitor UUID. It returns -1 if neither matches.
+func rejectedIndex(chunk []client.CheckResult, rej client.Rejection) int {
+	if rej.Index >= 0 && rej.Index < len(chunk) &&
+		(rej.MonitorUUID == "" || chunk[rej.Index].MonitorUUID == rej.MonitorUUID) {
+		return rej.Index
+	}
+	if rej.MonitorUUID != "" {
+		for i := range chunk {
+			if chunk[i].MonitorUUID == rej.MonitorUUID {
+				return i
+			}
+		}
+	}
+	return -1
+}
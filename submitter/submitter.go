@@ -0,0 +1,375 @@
+package submitter
+
+import (
+	"appoller/client"
+	"appoller/spool"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// envelopeBytes approximates the request JSON around the results array.
+const envelopeBytes = 128
+
+// maxRejectedAttempts is how many times a result the API keeps rejecting as
+// retryable is submitted before it is treated as permanently rejected.
+const maxRejectedAttempts = 5
+
+// SubmitFunc sends one chunk of results to the API.
+type SubmitFunc func(ctx context.Context, results []client.CheckResult) (*client.SubmitResultsResponse, error)
+
+// Options configures a Submitter.
+type Options struct {
+	MaxResults   int          // results per request
+	MaxBytes     int          // encoded results per request; a single larger result is sent alone
+	Parallelism  int          // requests in flight at once
+	Spool        *spool.Spool // optional durable store for undelivered results
+	OnBufferSize func(n int)  // called whenever the in-memory buffer changes size
+}
+
+// Submitter buffers check results and delivers them in chunks bounded by
+// count and encoded size. Chunks that fail to reach the API or hit a server
+// error, and results the API rejects as retryable, are kept: on disk when a
+// spool is configured and otherwise at the front of the buffer. Permanently
+// rejected results, including whole chunks refused with a 4xx status and
+// results still rejected after maxRejectedAttempts submissions, are logged,
+// counted and dropped.
+type Submitter struct {
+	submit SubmitFunc
+	opts   Options
+
+	mu      sync.Mutex
+	buffer  []client.CheckResult
+	retries map[string]int // submissions of results rejected as retryable, by resultKey
+
+	rejections rejectionLog
+}
+
+// New creates a Submitter.
+func New(submit SubmitFunc, opts Options) *Submitter {
+	if opts.MaxResults <= 0 {
+		opts.MaxResults = 100
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = 1
+	}
+	return &Submitter{submit: submit, opts: opts, retries: make(map[string]int)}
+}
+
+// Add buffers a result for the next Flush.
+func (s *Submitter) Add(r client.CheckResult) {
+	s.mu.Lock()
+	s.buffer = append(s.buffer, r)
+	s.bufferChanged()
+	s.mu.Unlock()
+}
+
+// Len returns the number of buffered results.
+func (s *Submitter) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.buffer)
+}
+
+// Flush submits the buffered results. When the buffer is empty, or every
+// chunk was delivered, it goes on to deliver spooled results.
+func (s *Submitter) Flush(ctx context.Context) {
+	batch := s.take()
+	if len(batch) == 0 {
+		// Nothing new; use the quiet tick to catch up on the spool
+		s.drainSpool(ctx)
+		return
+	}
+
+	failed, _ := s.send(ctx, batch)
+	if len(failed) > 0 {
+		s.requeue(failed)
+		return
+	}
+	// The API is reachable again; deliver anything spooled
+	s.drainSpool(ctx)
+}
+
+// Spill moves buffered results to the spool without contacting the API, for
+// use while the poller is offline. Without a spool results stay buffered.
+func (s *Submitter) Spill() {
+	if s.opts.Spool == nil {
+		return
+	}
+	if batch := s.take(); len(batch) > 0 {
+		s.requeue(batch)
+	}
+}
+
+// Shutdown makes a final attempt to submit buffered results, unless online
+// is false, and spools whatever could not be delivered.
+func (s *Submitter) Shutdown(ctx context.Context, online bool) {
+	remaining := s.take()
+	if len(remaining) > 0 {
+		log.Printf("[submitter] flushing %d remaining results", len(remaining))
+		if online {
+			remaining, _ = s.send(ctx, remaining)
+		}
+		if len(remaining) > 0 {
+			if s.opts.Spool == nil {
+				log.Printf("[submitter] %d results could not be delivered", len(remaining))
+			} else if err := s.opts.Spool.Append(remaining); err != nil {
+				log.Printf("[submitter] failed to spool results, %d lost: %v", len(remaining), err)
+			} else {
+				log.Printf("[submitter] spooled %d results for delivery after restart", len(remaining))
+			}
+		}
+	}
+	if s.opts.Spool != nil {
+		s.opts.Spool.Close()
+	}
+}
+
+// Rejections reports per-result rejections since startup.
+func (s *Submitter) Rejections() RejectionReport {
+	return s.rejections.report()
+}
+
+// take empties the buffer and returns its contents.
+func (s *Submitter) take() []client.CheckResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch := s.buffer
+	s.buffer = nil
+	s.bufferChanged()
+	return batch
+}
+
+// requeue keeps undelivered results: on disk when a spool is configured,
+// otherwise at the front of the in-memory buffer.
+func (s *Submitter) requeue(batch []client.CheckResult) {
+	if s.opts.Spool != nil {
+		err := s.opts.Spool.Append(batch)
+		if err == nil {
+			return
+		}
+		log.Printf("[submitter] failed to spool %d results, keeping in memory: %v", len(batch), err)
+	}
+	s.mu.Lock()
+	s.buffer = append(batch, s.buffer...)
+	s.bufferChanged()
+	s.mu.Unlock()
+}
+
+// bufferChanged reports the buffer size. Callers hold s.mu.
+func (s *Submitter) bufferChanged() {
+	if s.opts.OnBufferSize != nil {
+		s.opts.OnBufferSize(len(s.buffer))
+	}
+}
+
+// drainSpool submits spooled segments oldest-first until one fails.
+func (s *Submitter) drainSpool(ctx context.Context) {
+	if s.opts.Spool == nil {
+		return
+	}
+	for ctx.Err() == nil {
+		seg, err := s.opts.Spool.Next()
+		if err != nil {
+			log.Printf("[submitter] failed to read spool: %v", err)
+			return
+		}
+		if seg == nil {
+			return
+		}
+
+		failed, delivered := s.send(ctx, seg.Results)
+		if !delivered {
+			// Nothing reached the API; leave the segment for the next attempt
+			return
+		}
+		if len(failed) > 0 {
+			// Keep only the undelivered part, behind newer segments, so
+			// results the API keeps rejecting don't hold up the rest
+			if err := s.opts.Spool.Append(failed); err != nil {
+				log.Printf("[submitter] failed to respool %d results: %v", len(failed), err)
+				return
+			}
+		}
+		if err := s.opts.Spool.Commit(seg); err != nil {
+			log.Printf("[submitter] %v", err)
+			return
+		}
+		if len(failed) > 0 {
+			return
+		}
+	}
+}
+
+// send submits results in chunks, at most Parallelism at a time, and returns
+// the results of the chunks that failed transiently, plus retryable
+// rejections, in their original order. delivered reports whether any chunk
+// got an answer from the API.
+func (s *Submitter) send(ctx context.Context, results []client.CheckResult) (failed []client.CheckResult, delivered bool) {
+	if len(results) == 0 {
+		return nil, false
+	}
+	chunks := Split(results, s.opts.MaxResults, s.opts.MaxBytes)
+
+	type outcome struct {
+		resp *client.SubmitResultsResponse
+		err  error
+	}
+	outcomes := make([]outcome, len(chunks))
+
+	sem := make(chan struct{}, s.opts.Parallelism)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func() {
+			defer wg.Done()
+			defer func() { <-sem }()
+			resp, err := s.submit(ctx, chunk)
+			outcomes[i] = outcome{resp: resp, err: err}
+		}()
+	}
+	wg.Wait()
+
+	var sent, accepted, rejected, failedChunks int
+	for i, o := range outcomes {
+		if code, ok := refusedStatus(o.err); ok {
+			delivered = true
+			log.Printf("[submitter] dropping chunk %d/%d (%d results) refused by the API: %v", i+1, len(chunks), len(chunks[i]), o.err)
+			rej := client.Rejection{Reason: fmt.Sprintf("http_%d", code)}
+			for j := range chunks[i] {
+				s.rejections.record(&chunks[i][j], rej)
+			}
+			continue
+		}
+		if o.err != nil {
+			log.Printf("[submitter] failed to submit chunk %d/%d (%d results): %v", i+1, len(chunks), len(chunks[i]), o.err)
+			failed = append(failed, chunks[i]...)
+			failedChunks++
+			continue
+		}
+		sent += len(chunks[i])
+		accepted += o.resp.Accepted
+		rejected += o.resp.Rejected
+		failed = append(failed, s.handleRejections(chunks[i], o.resp.Rejections)...)
+	}
+	if sent > 0 {
+		log.Printf("[submitter] submitted %d results in %d chunks (accepted: %d, rejected: %d)",
+			sent, len(chunks)-failedChunks, accepted, rejected)
+	}
+	return failed, delivered || sent > 0
+}
+
+// handleRejections records the rejections for a delivered chunk and returns
+// the results that may be resubmitted. A result rejected as retryable for the
+// maxRejectedAttempts-th time is recorded as permanently rejected instead.
+func (s *Submitter) handleRejections(chunk []client.CheckResult, rejections []client.Rejection) []client.CheckResult {
+	var retry []client.CheckResult
+	seen := make(map[int]bool, len(rejections))
+	retried := make(map[string]bool)
+	for _, rej := range rejections {
+		idx := rejectedIndex(chunk, rej)
+		if idx < 0 || seen[idx] {
+			log.Printf("[submitter] ignoring rejection for unknown result (index %d, monitor %q): %s",
+				rej.Index, rej.MonitorUUID, rej.Reason)
+			continue
+		}
+		seen[idx] = true
+		r := &chunk[idx]
+		if rej.Retryable && s.countRetry(r) >= maxRejectedAttempts {
+			log.Printf("[submitter] result for monitor %s checked at %s rejected %d times, giving up",
+				r.MonitorUUID, r.CheckedAt, maxRejectedAttempts)
+			rej.Retryable = false
+		}
+		s.rejections.record(r, rej)
+		if rej.Retryable {
+			retried[resultKey(r)] = true
+			retry = append(retry, *r)
+			continue
+		}
+		log.Printf("[submitter] result for monitor %s checked at %s rejected: %s",
+			r.MonitorUUID, r.CheckedAt, rej.Reason)
+	}
+
+	// Every other result in the chunk is done with, delivered or dropped
+	s.mu.Lock()
+	if len(s.retries) > 0 {
+		for i := range chunk {
+			if key := resultKey(&chunk[i]); !retried[key] {
+				delete(s.retries, key)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	if len(retry) > 0 {
+		log.Printf("[submitter] %d rejected results will be resubmitted", len(retry))
+	}
+	return retry
+}
+
+// countRetry counts a retryable rejection of r and returns how many times r
+// has now been rejected as retryable.
+func (s *Submitter) countRetry(r *client.CheckResult) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	key := resultKey(r)
+	s.retries[key]++
+	return s.retries[key]
+}
+
+// resultKey identifies a result across resubmissions.
+func resultKey(r *client.CheckResult) string {
+	return r.MonitorUUID + "@" + r.CheckedAt
+}
+
+// refusedStatus reports whether err is an API answer that resubmitting the
+// same chunk cannot change: any 4xx status other than 408 and 429.
+func refusedStatus(err error) (int, bool) {
+	var statusErr *client.StatusError
+	if !errors.As(err, &statusErr) {
+		return 0, false
+	}
+	code := statusErr.StatusCode
+	if code < 400 || code >= 500 || code == http.StatusRequestTimeout || code == http.StatusTooManyRequests {
+		return 0, false
+	}
+	return code, true
+}
+
+// Split divides results into chunks of at most maxResults results whose
+// encoded size stays within maxBytes. A result that alone exceeds maxBytes
+// gets a chunk of its own. maxBytes <= 0 disables the size bound.
+func Split(results []client.CheckResult, maxResults, maxBytes int) [][]client.CheckResult {
+	var chunks [][]client.CheckResult
+	start, size := 0, envelopeBytes
+	for i := range results {
+		n := 0
+		if maxBytes > 0 {
+			n = encodedSize(&results[i]) + 1 // comma separator
+		}
+		count := i - start
+		if count > 0 && (count >= maxResults || (maxBytes > 0 && size+n > maxBytes)) {
+			chunks = append(chunks, results[start:i:i])
+			start, size = i, envelopeBytes
+		}
+		size += n
+	}
+	if start < len(results) {
+		chunks = append(chunks, results[start:len(results):len(results)])
+	}
+	return chunks
+}
+
+// encodedSize returns the length of r's JSON encoding.
+func encodedSize(r *client.CheckResult) int {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return 0
+	}
+	return len(data)
+}